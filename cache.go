@@ -1,47 +1,199 @@
+// Package objectCache 本地对象缓存，按访问频率和访问稳定性淘汰对象，详见README.md。
+//
+// 与早期版本不兼容的修改：
+//
+// Set()、SetInt()、SetByTopic()、SetIntByTopic()等存储函数返回error（被准入策略或者严格容量模式拒绝时为ErrRejected），
+// 忽略返回值的调用不需要修改，作为没有返回值的函数类型（如func([]byte, interface{}, int)）使用时需要包装。
+//
+// initialQueue休息期间没有被访问的对象被淘汰后不再同时放入restQueue[0]，只有被访问过的对象（以及固定、设置了优先级的对象）进入restQueue。
 package objectCache
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
 	"objectCache/internal"
 	"objectCache/internal/controller"
 	"objectCache/internal/storage"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var defaultTopic = []byte("_DefaultTopic_")
 var c *objectCache
-var objectCacheOnce sync.Once
+
+// initMu 保护初始化，c不为nil说明已经初始化
+var initMu sync.Mutex
 
 // 整个cache主要包含3个部分：
-// segments: 用于存储对象，使用了256个storage.Storage组成，每一个storage.Storage持有一个读写锁，这样实现就减小了锁的粒度，整个cache就支持最大256个并发操作。
-// nodeCache：是internal.Node(是存储对象用的，是cache存储的基本单元)的缓存池，避免动态创建internal.Node，整个cache就大幅减小对GC的压力。
+// segments: 用于存储对象，由多个storage.Storage组成（默认256个，CPU核数较多时更多，见Options.Shards），每一个storage.Storage持有一个读写锁，这样实现就减小了锁的粒度。
 // controller：对象控制器，用于对所有存储对象进行监控，根据对象的访问频率和访问的稳定性进行淘汰，还会删除到期的对象。
 type objectCache struct {
-	segments   [storage.MaxSegmentSize]*storage.Storage
-	nodeCache  *internal.NodeCache
+	segments   *storage.Segments
 	controller *controller.Controller
+
+	capacityPolicy CapacityPolicy
+	intakePolicy   IntakePolicy
+	// 严格容量模式下每次同步淘汰的数量
+	evictBatch int
+
+	// 准入策略使用的访问频率估算，为nil则没有启用准入策略
+	sketch *internal.FrequencySketch
+	// 是否对所有topic启用准入策略
+	admissionEnabled bool
+	// 单独设置的topic是否启用准入策略（topic -> bool）
+	admissionTopics sync.Map
+
+	// topic的编号（topic -> uint32），topicSeq为最后分配的编号，不超过maxTopics
+	topicIDs  sync.Map
+	topicSeq  uint32
+	maxTopics uint32
+	topicLock sync.Mutex
+
+	// 分布式失效，为nil则没有启用
+	bus *invalidationBus
+
+	// 序列化存储模式使用的编解码，为nil则对象直接保存在Node.Obj中
+	codec Codec
+	// 序列化存储模式下编码后对象的最大大小
+	maxValueSize int
+
+	// 序列化存储模式下所有对象编码后的最大字节数，为0则不限制
+	maxBytes int64
+
+	// expireSecond为0的对象的过期时间，为0则不过期
+	defaultTTL int
+	// 单独设置的topic的过期时间配置（topic -> TTLConfig）
+	topicTTLs sync.Map
+	// 过期时间的随机抖动，所有分段共享
+	jitter *internal.TTLJitter
+
+	// 对象被淘汰、过期删除后的回调
+	onEvict  RemoveFunc
+	onExpire RemoveFunc
+
+	// 生效的配置（填充默认值后），见Config()
+	opts Options
+
+	// 正在加载的对象（hash -> *loadCall），见GetOrLoad()
+	loads sync.Map
+
+	// 是否已经关闭，关闭后done被关闭
+	closed uint32
+	done   chan struct{}
 }
 
+// 严格容量模式下，同步淘汰后仍然没有名额的重试次数
+const maxEvictRetry = 3
+
+// 删除过期对象的间隔
+const sweepInterval = time.Second
+
 // InitObjectCache 初始化缓存集合
 // objMaxCount 参数用于限制最大缓存数量，其范围为[1w ~ 10000w]，如果objMaxCount没有在这个范围，则采用默认值100w
 func InitObjectCache(objMaxCount int32) {
-	objectCacheOnce.Do(func() {
-		c = &objectCache{
-			nodeCache: internal.NewNodeCache(objMaxCount / 4),
-		}
+	_ = InitObjectCacheWithOptions(Options{ObjMaxCount: objMaxCount})
+}
 
-		for i := 0; i < storage.MaxSegmentSize; i++ {
-			c.segments[i] = &storage.Storage{NodeMap: make(map[uint64]*internal.Node)}
-		}
+// InitObjectCacheWithOptions 根据配置初始化缓存集合，配置不合法或者订阅失效消息失败则返回错误。
+// 与InitObjectCache一样只有第一次成功的调用生效，初始化失败后可以再次调用。
+func InitObjectCacheWithOptions(opts Options) (err error) {
+	_, err = initObjectCache(opts)
+	return err
+}
+
+// initObjectCache 根据配置初始化缓存集合，initialized为是否是本次调用完成的初始化。
+// 初始化失败时不保留任何状态，可以再次初始化
+func initObjectCache(opts Options) (initialized bool, err error) {
+	opts, err = opts.normalize()
+	if err != nil {
+		return false, err
+	}
+
+	initMu.Lock()
+	defer initMu.Unlock()
+	if c != nil {
+		return false, nil
+	}
+
+	if opts.Clock != nil {
+		internal.SetClock(opts.Clock)
+	}
+	if opts.Logger != nil {
+		internal.SetLogger(opts.Logger)
+	}
+
+	cc, err := newObjectCache(opts)
+	if err != nil {
+		internal.SetClock(nil)
+		internal.SetLogger(nil)
+		return false, err
+	}
+	c = cc
+	return true, nil
+}
 
-		if objMaxCount > 1e8 || objMaxCount < 1e4 {
-			objMaxCount = 1e6
+// newObjectCache 根据配置（已经填充默认值）创建缓存集合并启动处理协程，订阅失效消息失败则关闭后返回错误
+func newObjectCache(opts Options) (c *objectCache, err error) {
+	c = &objectCache{
+		capacityPolicy:   opts.CapacityPolicy,
+		intakePolicy:     opts.IntakePolicy,
+		evictBatch:       int(opts.ObjMaxCount/1000) + 1,
+		admissionEnabled: opts.Admission.Enabled,
+		codec:            opts.Codec,
+		maxValueSize:     opts.MaxValueSize,
+		maxBytes:         opts.MaxBytes,
+		defaultTTL:       opts.DefaultTTL,
+		onEvict:          opts.OnEvict,
+		onExpire:         opts.OnExpire,
+		opts:             opts,
+		jitter:           internal.NewTTLJitter(uint32(opts.TTLJitter)),
+		maxTopics:        uint32(opts.MaxTopics),
+		done:             make(chan struct{}),
+	}
+	for topic, cfg := range opts.TopicTTL {
+		// normalize()已经检查了topic的个数
+		_ = c.setTopicTTL(topic, cfg)
+	}
+
+	if opts.Admission.enabled() {
+		c.sketch = internal.NewFrequencySketch(opts.ObjMaxCount)
+	}
+	for topic, enabled := range opts.Admission.Topics {
+		c.admissionTopics.Store(topic, enabled)
+	}
+
+	c.segments = storage.NewSegments(opts.Shards, opts.Eviction.NodeUnitRestTime)
+	for _, segment := range c.segments.List {
+		if c.codec != nil {
+			segment.EnableArena(opts.ArenaSize, opts.OffHeap)
+		}
+		if opts.LockFreeReads {
+			segment.EnableLockFreeReads()
+		}
+		segment.SetTTLJitter(c.jitter)
+		if opts.SlidingExpiration {
+			segment.EnableSlidingExpiration()
 		}
-		c.controller = controller.NewController(objMaxCount, &c.segments, c.nodeCache)
-	})
+		if c.onEvict != nil || c.onExpire != nil {
+			segment.SetRemoveHook(c.removed)
+		}
+	}
 
+	c.controller = controller.NewController(opts.ObjMaxCount, opts.Eviction, c.segments, opts.ControllerShards,
+		int64(opts.IntakeLimit), c.sketch)
+	go c.sweepExpired()
+
+	if opts.Transport != nil {
+		if c.bus, err = newInvalidationBus(opts.Transport, c.applyInvalidation); err != nil {
+			_ = c.close()
+			return nil, fmt.Errorf("objectCache: 订阅失效消息失败: %w", err)
+		}
+	}
+	return c, nil
 }
 
 // InitDefaultObjectCache 初始化缓存集合，最大缓存数量为默认值8*65535
@@ -49,65 +201,373 @@ func InitDefaultObjectCache() {
 	InitObjectCache(0)
 }
 
-func set(key []byte, obj interface{}, expireSecond int) {
+// set 存储对象，topic只用于判断是否启用准入策略，key中已经包含了topic。priority只对新增的对象有效。
+// ctx用于可能阻塞的操作：积压达到上限时等待（IntakeBlock、IntakeEvict）、严格容量模式下的同步淘汰
+func set(ctx context.Context, topic string, key []byte, obj interface{}, expireSecond int, priority Priority) (err error) {
+	if c == nil {
+		return ErrNotInitialized
+	}
+	if c.isClosed() {
+		return ErrClosed
+	}
 
 	hashVal := internal.HashFunc(key)
-	segID := hashVal % storage.MaxSegmentSize
+	segID := c.segments.Index(hashVal)
+
+	if obj, err = c.encode(obj); err != nil {
+		return err
+	}
+
+	// topic的个数达到上限
+	topicID, ok := c.topicID(topic)
+	if !ok {
+		return ErrRejected
+	}
 
-	n := c.nodeCache.GetNode()
-	ok := c.segments[segID].Set(obj, hashVal, expireSecond, n)
+	if !c.admit(topic, hashVal, segID) {
+		return ErrRejected
+	}
+
+	if c.maxBytes > 0 {
+		if err = c.reserveBytes(ctx, int64(len(obj.([]byte)))); err != nil {
+			return err
+		}
+	}
+
+	expireSecond = c.ttl(topic, expireSecond)
+
+	if c.controller.Congested(hashVal) {
+		switch c.intakePolicy {
+		case IntakeBlock:
+			if err = c.controller.WaitIntake(ctx, hashVal); err != nil {
+				return err
+			}
+		case IntakeEvict:
+			// 对象数量离最大缓存数量还远时只等待（与IntakeBlock相同），避免短时间的写入高峰淘汰有效的对象
+			if c.controller.NearCapacity() {
+				_, err = c.controller.EvictShard(ctx, hashVal, c.evictBatch)
+			} else {
+				err = c.controller.WaitIntake(ctx, hashVal)
+			}
+			if err != nil {
+				return err
+			}
+		case IntakeDirect:
+			// 已经存在的对象仍然纳入淘汰管理，只更新对象
+			if n, _ := c.segments.List[segID].SetDirect(obj, hashVal, expireSecond, topicID); n == nil {
+				return ErrRejected
+			}
+			c.publishDel(hashVal)
+			return nil
+		}
+	}
+
+	for {
+		reserved, err := c.reserve(ctx, hashVal, segID)
+		if err != nil {
+			return err
+		}
+		if reserved {
+			break
+		}
+		// 严格容量模式下更新已经存在的对象不占用名额，对象在更新前被删除则重新占用名额
+		n, ok := c.segments.List[segID].Update(obj, hashVal, expireSecond)
+		if n != nil {
+			c.publishDel(hashVal)
+			return nil
+		}
+		if ok {
+			return ErrRejected
+		}
+	}
+
+	// 序列化存储模式下分段的字节区超过最大大小
+	n, ok := c.segments.List[segID].Set(obj, hashVal, expireSecond, topicID)
+	if n == nil {
+		c.controller.Release()
+		return ErrRejected
+	}
 	if ok {
+		if priority != PriorityNormal {
+			n.SetPriority(uint8(priority))
+		}
 		c.controller.AddNode(n)
 	} else {
-		c.nodeCache.SaveNode(n)
+		c.controller.Release()
+	}
+
+	c.publishDel(hashVal)
+	return nil
+}
+
+// admit 准入判断，只有启用准入策略、对象数量达到开始淘汰的比例并且是新增对象时才做判断
+func (c *objectCache) admit(topic string, hash uint64, segID uint64) (ok bool) {
+	if c.sketch == nil {
+		return true
 	}
+
+	c.sketch.Increment(hash)
+
+	if !c.controller.NearCapacity() || !c.admissionEnabledFor(topic) {
+		return true
+	}
+
+	return c.controller.Admit(hash) || c.segments.List[segID].Has(hash)
+}
+
+// admissionEnabledFor 判断topic是否启用准入策略
+func (c *objectCache) admissionEnabledFor(topic string) (ok bool) {
+	if v, exist := c.admissionTopics.Load(topic); exist {
+		return v.(bool)
+	}
+	return c.admissionEnabled
+}
+
+// reserve 为新增的对象占用名额。严格容量模式下对象数量达到最大缓存数量时，更新已经存在的对象不占用名额，
+// 新增对象则根据策略同步淘汰或者返回ErrRejected。同步淘汰时ctx结束则返回ctx.Err()
+func (c *objectCache) reserve(ctx context.Context, hash uint64, segID uint64) (reserved bool, err error) {
+	if c.capacityPolicy == CapacitySoft {
+		return c.controller.Reserve(false), nil
+	}
+
+	for i := 0; i < maxEvictRetry; i++ {
+		if c.controller.Reserve(true) {
+			return true, nil
+		}
+
+		if c.segments.List[segID].Has(hash) {
+			return false, nil
+		}
+
+		if c.capacityPolicy == CapacityReject {
+			return false, ErrRejected
+		}
+		count, err := c.controller.Evict(ctx, c.evictBatch)
+		if err != nil {
+			return false, err
+		}
+		if count == 0 {
+			return false, ErrRejected
+		}
+	}
+
+	return false, ErrRejected
 }
 
+// reserveBytes 序列化存储模式下为size字节的对象腾出空间：所有对象的字节数超出MaxBytes时同步淘汰，
+// CapacityReject或者无法淘汰时返回ErrRejected，对象本身超过MaxBytes则返回ErrTooLarge。
+// 更新已经存在的对象时不扣除原来的大小，只是提前淘汰
+func (c *objectCache) reserveBytes(ctx context.Context, size int64) (err error) {
+	if size > c.maxBytes {
+		return ErrTooLarge
+	}
+
+	for i := 0; c.segments.Bytes()+size > c.maxBytes; i++ {
+		if i == maxEvictRetry || c.capacityPolicy == CapacityReject {
+			return ErrRejected
+		}
+		count, err := c.controller.Evict(ctx, c.evictBatch)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrRejected
+		}
+	}
+	return nil
+}
+
+// ttl 存储时的过期时间（单位是秒），expireSecond为0则使用topic或者缓存的默认过期时间。随机抖动由storage计算
+func (c *objectCache) ttl(topic string, expireSecond int) int {
+	if expireSecond != 0 {
+		return expireSecond
+	}
+	if v, ok := c.topicTTLs.Load(topic); ok {
+		if ttl := v.(TTLConfig).Default; ttl != 0 {
+			return ttl
+		}
+	}
+	return c.defaultTTL
+}
+
+// setTopicTTL 单独设置topic的过期时间配置，cfg为零值则恢复为缓存的配置。topic的个数达到上限时返回false
+func (c *objectCache) setTopicTTL(topic string, cfg TTLConfig) (ok bool) {
+	id, ok := c.topicID(topic)
+	if !ok {
+		return false
+	}
+
+	if cfg == (TTLConfig{}) {
+		c.topicTTLs.Delete(topic)
+	} else {
+		c.topicTTLs.Store(topic, cfg)
+	}
+
+	switch {
+	case cfg.Jitter == NoJitter:
+		c.jitter.SetTopic(id, 0)
+	case cfg.Jitter > 0:
+		c.jitter.SetTopic(id, uint32(cfg.Jitter))
+	default:
+		c.jitter.ResetTopic(id)
+	}
+	return true
+}
+
+// removed storage中的对象被淘汰、过期删除后调用，序列化存储模式下解码后回调（解码失败则不回调）
+func (c *objectCache) removed(hash uint64, value interface{}, reason storage.RemoveReason) {
+	fn := c.onEvict
+	if reason == storage.RemoveExpired {
+		fn = c.onExpire
+	}
+	if fn == nil {
+		return
+	}
+
+	if c.codec != nil {
+		obj, err := c.codec.Unmarshal(value.([]byte))
+		if err != nil {
+			return
+		}
+		value = obj
+	}
+	fn(hash, value)
+}
+
+// sweepExpired 定时删除过期的对象。只处理时间轮中到期的对象，不需要扫描全部对象
+func (c *objectCache) sweepExpired() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+
+		current := internal.Now()
+		for _, segment := range c.segments.List {
+			segment.Sweep(current, c.recycle)
+			segment.Reclaim()
+		}
+	}
+}
+
+// isClosed 是否已经关闭
+func (c *objectCache) isClosed() (ok bool) {
+	return atomic.LoadUint32(&c.closed) != 0
+}
+
+// Close 关闭缓存：停止删除过期对象和controller的处理协程，关闭分布式失效的Transport。
+// 关闭后存储对象返回ErrClosed，已经存储的对象仍然可以读取（不再过期删除、淘汰），不能再次初始化
+func Close() (err error) {
+	if c == nil {
+		return ErrNotInitialized
+	}
+	return c.close()
+}
+
+// close 停止处理协程并关闭Transport，重复调用直接返回
+func (c *objectCache) close() (err error) {
+	if !atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		return nil
+	}
+
+	close(c.done)
+	c.controller.Close()
+	if c.bus != nil {
+		err = c.bus.transport.Close()
+	}
+	return err
+}
+
+// recycle 从storage中删除对象后调用，纳入淘汰管理的对象释放占用的名额。
+// node由storage回收：纳入淘汰管理的node仍在controller的队列中，交由controller清除hash后回收
+func (c *objectCache) recycle(n *internal.Node) {
+	if !n.IsDirect() {
+		c.controller.Release()
+	}
+}
+
+// KeyHash 计算键值的hash，与RemoveFunc等回调中的hash相同。topic为空则使用默认 _DefaultTopic_
+func KeyHash(topic string, key []byte) (hash uint64) {
+	return internal.HashFunc(topicKey(topic, key))
+}
+
+// get 获取对象，缓存没有初始化时返回false
 func get(key []byte) (obj interface{}, ok bool) {
+	if c == nil {
+		return nil, false
+	}
 	hashVal := internal.HashFunc(key)
-	segID := hashVal % storage.MaxSegmentSize
-	node, ok := c.segments[segID].Get(hashVal)
+	segID := c.segments.Index(hashVal)
+	if c.sketch != nil {
+		c.sketch.Increment(hashVal)
+	}
+	return c.load(hashVal, segID)
+}
+
+// load 获取对象，对象过期则删除。序列化存储模式下解码，解码失败视为对象不存在
+func (c *objectCache) load(hash uint64, segID uint64) (obj interface{}, ok bool) {
+	_, obj, expire, ok := c.segments.List[segID].GetValue(hash)
 	if !ok {
 		return nil, false
 	}
 
-	if node.Expire != math.MaxUint32 && uint32(time.Now().Unix()) > node.Expire {
-		n, ok := c.segments[segID].Del(hashVal)
-		if ok {
-			c.nodeCache.SaveDirtyNode(n)
+	if expire != math.MaxUint32 && internal.Now() > expire {
+		if c.delHash(hash, segID) && c.onExpire != nil {
+			c.removed(hash, obj, storage.RemoveExpired)
 		}
 		return nil, false
 	}
 
-	return node.Obj, ok
+	if c.codec != nil {
+		if obj, err := c.codec.Unmarshal(obj.([]byte)); err == nil {
+			return obj, true
+		}
+		return nil, false
+	}
+	return obj, true
 }
 
+// del 删除对象，并通知其他进程删除（启用了分布式失效时）。缓存没有初始化时返回false
 func del(key []byte) (ok bool) {
+	if c == nil {
+		return false
+	}
 	hashVal := internal.HashFunc(key)
-	segID := hashVal % storage.MaxSegmentSize
-	var n *internal.Node
+	segID := c.segments.Index(hashVal)
 
-	n, ok = c.segments[segID].Del(hashVal)
+	ok = c.delHash(hashVal, segID)
+	c.publishDel(hashVal)
+	return ok
+}
+
+// delHash 根据hash删除对象
+func (c *objectCache) delHash(hash uint64, segID uint64) (ok bool) {
+	n, ok := c.segments.List[segID].Del(hash)
 	if ok {
-		c.nodeCache.SaveDirtyNode(n)
+		c.recycle(n)
 	}
-
 	return ok
 }
 
 // set 缓存字符切片为键值的对象。使用默认 _DefaultTopic_
 // key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期
-func Set(key []byte, obj interface{}, expireSecond int) {
+// 被准入策略拒绝，或者严格容量模式下对象数量达到最大缓存数量并且无法淘汰时返回ErrRejected
+func Set(key []byte, obj interface{}, expireSecond int) (err error) {
 	key = append(key, defaultTopic...)
-	set(key, obj, expireSecond)
+	return set(context.Background(), "", key, obj, expireSecond, PriorityNormal)
 }
 
 // SetInt 缓存一个以int型KEY的对象。使用默认 _DefaultTopic_
 // key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期
-func SetInt(key int64, obj interface{}, expireSecond int) {
+// 被准入策略拒绝，或者严格容量模式下对象数量达到最大缓存数量并且无法淘汰时返回ErrRejected
+func SetInt(key int64, obj interface{}, expireSecond int) (err error) {
 	var bKey [internal.DefaultKeySize]byte
 	binary.LittleEndian.PutUint64(bKey[:], uint64(key))
-	Set(bKey[:], obj, expireSecond)
+	return Set(bKey[:], obj, expireSecond)
 }
 
 // Get 根据字符切片型键值获取对象。使用默认 _DefaultTopic_
@@ -143,19 +603,21 @@ func DelInt(key int64) (ok bool) {
 
 // SetByTopic 缓存字符切片为键值的对象，当对象已经存在返回false。topic为空则使用默认 _DefaultTopic_
 // key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期
-func SetByTopic(topic string, key []byte, obj interface{}, expireSecond int) {
+// 被准入策略拒绝，或者严格容量模式下对象数量达到最大缓存数量并且无法淘汰时返回ErrRejected
+func SetByTopic(topic string, key []byte, obj interface{}, expireSecond int) (err error) {
 	if topic == "" {
 		key = append(key, defaultTopic...)
 	} else {
 		key = append(key, internal.String2Bytes(topic)...)
 	}
 
-	set(key, obj, expireSecond)
+	return set(context.Background(), topic, key, obj, expireSecond, PriorityNormal)
 }
 
 // SetInt 缓存一个以int型KEY的对象，当对象已经存在返回false。topic为空则使用默认 _DefaultTopic_
 // key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期
-func SetIntByTopic(topic string, key int64, obj interface{}, expireSecond int) {
+// 被准入策略拒绝，或者严格容量模式下对象数量达到最大缓存数量并且无法淘汰时返回ErrRejected
+func SetIntByTopic(topic string, key int64, obj interface{}, expireSecond int) (err error) {
 	var bKey [internal.DefaultKeySize]byte
 	binary.LittleEndian.PutUint64(bKey[:], uint64(key))
 	var hashKey []byte
@@ -165,7 +627,7 @@ func SetIntByTopic(topic string, key int64, obj interface{}, expireSecond int) {
 		hashKey = append(bKey[:], internal.String2Bytes(topic)...)
 	}
 
-	set(hashKey, obj, expireSecond)
+	return set(context.Background(), topic, hashKey, obj, expireSecond, PriorityNormal)
 }
 
 // Get 根据字符切片型键值获取对象，当对象不存在返回false。topic为空则使用默认 _DefaultTopic_
@@ -221,14 +683,58 @@ func DelIntByTopic(topic string, key int64) (ok bool) {
 	return Del(hashKey)
 }
 
-// GetObjCount 获取当前时刻存储对象的个数（是一个瞬时值，可能并不是你预期的值）。
+// GetObjCount 获取当前时刻存储对象的个数（是一个瞬时值，可能并不是你预期的值）。缓存没有初始化时返回0
 func GetObjCount() (count int32) {
+	if c == nil {
+		return 0
+	}
 	return c.controller.GetTotalCount()
 }
 
+// SetTopicAdmission 设置topic是否启用准入策略，默认topic使用空字符串。
+// 缓存没有初始化、已经关闭时返回ErrNotInitialized、ErrClosed，初始化时没有启用任何准入策略（Options.Admission）则返回错误
+func SetTopicAdmission(topic string, enabled bool) (err error) {
+	if err = checkState(); err != nil {
+		return err
+	}
+	if c.sketch == nil {
+		return errors.New("objectCache: 初始化时没有启用准入策略")
+	}
+	c.admissionTopics.Store(topic, enabled)
+	return nil
+}
+
+// SetTopicTTL 单独设置topic的默认过期时间、随机抖动，默认topic使用空字符串，cfg为零值则恢复为缓存的配置（Options.DefaultTTL、
+// Options.TTLJitter）。只影响之后存储的对象，配置不合法则返回错误（包装了ErrInvalidConfig），topic的个数达到上限（Options.MaxTopics）返回ErrRejected
+func SetTopicTTL(topic string, cfg TTLConfig) (err error) {
+	if err = checkState(); err != nil {
+		return err
+	}
+	if err = cfg.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if !c.setTopicTTL(topic, cfg) {
+		return ErrRejected
+	}
+	return nil
+}
+
+// Stats 缓存的统计信息，包括各个淘汰队列的对象数量、淘汰比例、平均访问频率、当前休息时间步长等
+type Stats = controller.Stats
+
+// GetStats 获取当前时刻缓存的统计信息（是一个瞬时值）。缓存没有初始化时返回零值
+func GetStats() (stats Stats) {
+	if c == nil {
+		return stats
+	}
+	return c.controller.GetStats()
+}
+
 // GetQueueCount 测试使用
 func GetQueueCount() (result string) {
-
+	if c == nil {
+		return ""
+	}
 	return c.controller.GetQueueCount()
 }
 
@@ -11,7 +11,7 @@ type testData struct {
 	name string
 }
 
-func ExampleNewCache() {
+func ExampleInitObjectCache() {
 	objectCache.InitObjectCache(1e5)
 	objectCache.InitDefaultObjectCache()
 	fmt.Println(objectCache.GetObjCount())
@@ -19,7 +19,7 @@ func ExampleNewCache() {
 	// 0
 }
 
-func ExampleCache_SetAndGet() {
+func ExampleSet() {
 	objectCache.InitDefaultObjectCache()
 
 	d := testData{id: 100, name: "test1"}
@@ -36,7 +36,7 @@ func ExampleCache_SetAndGet() {
 
 }
 
-func ExampleCache_SetIntAndGetInt() {
+func ExampleSetInt() {
 	objectCache.InitDefaultObjectCache()
 
 	d := testData{id: 1001, name: "SetIntAndGetInt"}
@@ -53,7 +53,7 @@ func ExampleCache_SetIntAndGetInt() {
 
 }
 
-func ExampleCache_SetExpire() {
+func Example_setExpire() {
 	objectCache.InitDefaultObjectCache()
 
 	d := testData{id: 1002, name: "SetExpire"}
@@ -73,7 +73,7 @@ func ExampleCache_SetExpire() {
 
 }
 
-func ExampleCache_SetAndGetByTopic() {
+func ExampleSetByTopic() {
 	objectCache.InitDefaultObjectCache()
 
 	d := testData{id: 1005, name: "SetAndGetByTopic"}
@@ -90,7 +90,7 @@ func ExampleCache_SetAndGetByTopic() {
 
 }
 
-func ExampleCache_SetIntAndGetIntByTopic() {
+func ExampleSetIntByTopic() {
 	objectCache.InitDefaultObjectCache()
 
 	d := testData{id: 1004, name: "SetIntAndGetIntByTopic"}
@@ -107,7 +107,7 @@ func ExampleCache_SetIntAndGetIntByTopic() {
 
 }
 
-func ExampleCache_SetExpireByTopic() {
+func Example_setExpireByTopic() {
 	objectCache.InitDefaultObjectCache()
 
 	d := testData{id: 1003, name: "SetExpireByTopic"}
@@ -126,3 +126,19 @@ func ExampleCache_SetExpireByTopic() {
 	// get failed
 
 }
+
+func ExamplePin() {
+	objectCache.InitDefaultObjectCache()
+
+	d := testData{id: 1006, name: "Pin"}
+
+	objectCache.SetWithPriority([]byte("Pin"), d, 0, objectCache.PriorityHighest)
+
+	// 固定后不会被淘汰
+	fmt.Println(objectCache.Pin([]byte("Pin")))
+	fmt.Println(objectCache.Pin([]byte("PinNotExist")))
+
+	// Output:
+	// true
+	// false
+}
@@ -0,0 +1,77 @@
+package objectCache
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestChurn 并发写入、删除、读取以及同步淘汰，读到的对象必须属于读取的key。需要使用-race运行：
+// go test -race -run Churn .
+func TestChurn(t *testing.T) {
+	InitDefaultObjectCache()
+
+	const (
+		keys    = 256
+		workers = 8
+	)
+	topics := []string{"churn", "churnDirect"}
+
+	var wrong int32
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(2 * time.Second)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for time.Now().Before(deadline) {
+				key := r.Int63n(keys)
+				switch topic := topics[r.Intn(len(topics))]; r.Intn(4) {
+				case 0:
+					if topic == "churnDirect" {
+						SetIntDirectByTopic(topic, key, key, 1+r.Intn(2))
+					} else {
+						_ = SetIntByTopic(topic, key, key, r.Intn(2))
+					}
+				case 1:
+					if topic == "churnDirect" {
+						DelIntDirectByTopic(topic, key)
+					} else {
+						DelIntByTopic(topic, key)
+					}
+				default:
+					get := GetIntByTopic
+					if topic == "churnDirect" {
+						get = GetIntDirectByTopic
+					}
+					if obj, ok := get(topic, key); ok && obj.(int64) != key {
+						atomic.AddInt32(&wrong, 1)
+					}
+				}
+			}
+		}(int64(w))
+	}
+
+	// 同时淘汰，controller删除node时不能影响同一个key重新存储的对象
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for time.Now().Before(deadline) {
+			_, _ = c.controller.Evict(context.Background(), keys/4)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	wg.Wait()
+
+	if wrong != 0 {
+		t.Error("失败1", wrong)
+	}
+
+	// 清除剩余的对象，不影响其他测试
+	_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+}
@@ -0,0 +1,72 @@
+package objectCache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// Codec 对象的编解码，用于需要把对象转换为字节的场景（如二级缓存）
+type Codec interface {
+	Marshal(obj interface{}) (data []byte, err error)
+	Unmarshal(data []byte) (obj interface{}, err error)
+}
+
+// GobCodec 使用encoding/gob编解码，可以保存任意类型的对象，自定义类型需要先调用gob.Register()注册
+type GobCodec struct{}
+
+// gobValue gob不能直接编码interface{}，包装后编码
+type gobValue struct {
+	Obj interface{}
+}
+
+func (GobCodec) Marshal(obj interface{}) (data []byte, err error) {
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(gobValue{Obj: obj}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte) (obj interface{}, err error) {
+	var v gobValue
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v.Obj, nil
+}
+
+// BytesCodec 对象本身就是[]byte或者string，不做转换，解码的结果为[]byte
+type BytesCodec struct{}
+
+func (BytesCodec) Marshal(obj interface{}) (data []byte, err error) {
+	switch v := obj.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("objectCache: BytesCodec不支持的类型%T", obj)
+}
+
+func (BytesCodec) Unmarshal(data []byte) (obj interface{}, err error) {
+	return data, nil
+}
+
+// encode 序列化存储模式下将对象编码为[]byte
+func (c *objectCache) encode(obj interface{}) (value interface{}, err error) {
+	if c.codec == nil {
+		return obj, nil
+	}
+	data, err := c.codec.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("objectCache: 对象编码失败: %w", err)
+	}
+	if len(data) > c.maxValueSize {
+		return nil, ErrTooLarge
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return data, nil
+}
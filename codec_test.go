@@ -0,0 +1,45 @@
+package objectCache
+
+import (
+	"testing"
+)
+
+type codecData struct {
+	ID   int
+	Name string
+}
+
+func TestGobCodec(t *testing.T) {
+	var codec Codec = GobCodec{}
+
+	for _, v := range []interface{}{1, "a", []byte("b"), 1.5} {
+		data, err := codec.Marshal(v)
+		if err != nil {
+			t.Error("失败1", err)
+		}
+		if _, err = codec.Unmarshal(data); err != nil {
+			t.Error("失败2", err)
+		}
+	}
+
+	// 没有注册的类型
+	if _, err := codec.Marshal(codecData{ID: 1}); err == nil {
+		t.Error("失败3")
+	}
+}
+
+func TestBytesCodec(t *testing.T) {
+	var codec Codec = BytesCodec{}
+
+	data, err := codec.Marshal("abc")
+	if err != nil || string(data) != "abc" {
+		t.Error("失败1")
+	}
+	if _, err = codec.Marshal(1); err == nil {
+		t.Error("失败2")
+	}
+	obj, _ := codec.Unmarshal(data)
+	if string(obj.([]byte)) != "abc" {
+		t.Error("失败3")
+	}
+}
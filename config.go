@@ -0,0 +1,159 @@
+package objectCache
+
+import (
+	"fmt"
+)
+
+// Option New()的配置项，按顺序修改Options
+type Option func(o *Options)
+
+// WithOptions 使用完整的配置（如从JSON、YAML配置文件解析得到的Options），之后的配置项在此基础上修改
+func WithOptions(opts Options) Option {
+	return func(o *Options) {
+		*o = opts
+	}
+}
+
+// WithCapacity 最大缓存数量，范围为[1w ~ 10000w]，见Options.ObjMaxCount
+func WithCapacity(count int32) Option {
+	return func(o *Options) {
+		o.ObjMaxCount = count
+	}
+}
+
+// WithCapacityPolicy 对象数量达到最大缓存数量后的处理策略，见Options.CapacityPolicy
+func WithCapacityPolicy(policy CapacityPolicy) Option {
+	return func(o *Options) {
+		o.CapacityPolicy = policy
+	}
+}
+
+// WithMaxBytes 序列化存储模式下所有对象编码后的最大字节数，见Options.MaxBytes
+func WithMaxBytes(size int64) Option {
+	return func(o *Options) {
+		o.MaxBytes = size
+	}
+}
+
+// WithShards 分段的个数，见Options.Shards
+func WithShards(count int) Option {
+	return func(o *Options) {
+		o.Shards = count
+	}
+}
+
+// WithControllerShards controller的分片个数，见Options.ControllerShards
+func WithControllerShards(count int) Option {
+	return func(o *Options) {
+		o.ControllerShards = count
+	}
+}
+
+// WithEviction 淘汰模型的参数，字段为0（千分比字段为nil）则使用默认值，千分比字段使用Permille()设置
+func WithEviction(cfg EvictionConfig) Option {
+	return func(o *Options) {
+		o.Eviction = cfg
+	}
+}
+
+// WithClock 时间来源，见Options.Clock
+func WithClock(clock Clock) Option {
+	return func(o *Options) {
+		o.Clock = clock
+	}
+}
+
+// WithLogger 日志输出，见Options.Logger
+func WithLogger(logger Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithOnEvict 对象被淘汰后的回调，见Options.OnEvict
+func WithOnEvict(fn RemoveFunc) Option {
+	return func(o *Options) {
+		o.OnEvict = fn
+	}
+}
+
+// WithOnExpire 对象过期删除后的回调，见Options.OnExpire
+func WithOnExpire(fn RemoveFunc) Option {
+	return func(o *Options) {
+		o.OnExpire = fn
+	}
+}
+
+// WithCodec 对象的编解码，启用序列化存储模式，见Options.Codec
+func WithCodec(codec Codec) Option {
+	return func(o *Options) {
+		o.Codec = codec
+	}
+}
+
+// WithDefaultTTL expireSecond为0的对象的过期时间（单位是秒），见Options.DefaultTTL
+func WithDefaultTTL(seconds int) Option {
+	return func(o *Options) {
+		o.DefaultTTL = seconds
+	}
+}
+
+// WithTTLJitter 过期时间的随机抖动（百分比），见Options.TTLJitter
+func WithTTLJitter(percent int) Option {
+	return func(o *Options) {
+		o.TTLJitter = percent
+	}
+}
+
+// WithTopicTTL 单独设置topic的默认过期时间、随机抖动，见Options.TopicTTL
+func WithTopicTTL(topic string, cfg TTLConfig) Option {
+	return func(o *Options) {
+		topics := make(map[string]TTLConfig, len(o.TopicTTL)+1)
+		for k, v := range o.TopicTTL {
+			topics[k] = v
+		}
+		topics[topic] = cfg
+		o.TopicTTL = topics
+	}
+}
+
+// WithSlidingExpiration 启用滑动过期，见Options.SlidingExpiration
+func WithSlidingExpiration() Option {
+	return func(o *Options) {
+		o.SlidingExpiration = true
+	}
+}
+
+// New 根据配置项初始化缓存集合。与InitObjectCacheWithOptions()不同，配置超出范围时不使用默认值而是返回错误（包装了ErrInvalidConfig），
+// 已经初始化（包括通过InitObjectCache()等）则返回ErrAlreadyInitialized。订阅失效消息失败时返回的错误包装了Transport的错误，之后可以再次调用
+func New(opts ...Option) (err error) {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.ObjMaxCount != 0 && (o.ObjMaxCount < 1e4 || o.ObjMaxCount > 1e8) {
+		return fmt.Errorf("%w: ObjMaxCount(%d)需要在[%d, %d]之间", ErrInvalidConfig, o.ObjMaxCount, int(1e4), int(1e8))
+	}
+
+	initialized, err := initObjectCache(o)
+	if err != nil {
+		return err
+	}
+	if !initialized {
+		return ErrAlreadyInitialized
+	}
+	return nil
+}
+
+// Config 返回生效的配置：填充了默认值（如Shards、ControllerShards、Eviction中为0的字段），超出范围的ObjMaxCount为实际使用的值。
+// 返回的配置（包括经过JSON、YAML编解码的）再次传给New()得到相同的配置
+func Config() (opts Options, err error) {
+	if c == nil {
+		return opts, ErrNotInitialized
+	}
+	opts = c.opts
+	// 复制千分比字段的指针，调用者修改不影响正在使用的配置
+	opts.Eviction = opts.Eviction.WithDefaults()
+	return opts, nil
+}
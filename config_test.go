@@ -0,0 +1,366 @@
+package objectCache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"objectCache/internal"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *testClock) add(d time.Duration) {
+	c.Lock()
+	c.now = c.now.Add(d)
+	c.Unlock()
+	internal.RefreshClock()
+}
+
+// withNew 使用New()创建新的缓存执行fn，结束后关闭并恢复原来的缓存
+func withNew(t *testing.T, fn func(), opts ...Option) {
+	InitDefaultObjectCache()
+	saved := c
+	c = nil
+	defer func() {
+		if c != saved {
+			_ = Close()
+		}
+		c = saved
+		internal.SetClock(nil)
+		internal.SetLogger(nil)
+	}()
+
+	if err := New(opts...); err != nil {
+		t.Fatal(err)
+	}
+	fn()
+}
+
+func TestNew_Validate(t *testing.T) {
+	cases := []struct {
+		opts []Option
+		msg  string
+	}{
+		{[]Option{WithCapacity(100)}, "ObjMaxCount(100)"},
+		{[]Option{WithCapacity(2e8)}, "ObjMaxCount(200000000)"},
+		{[]Option{WithMaxBytes(1 << 20)}, "MaxBytes需要设置Codec"},
+		{[]Option{WithCodec(BytesCodec{}), WithMaxBytes(-1)}, "MaxBytes(-1)"},
+		{[]Option{WithDefaultTTL(-1)}, "DefaultTTL(-1)"},
+		{[]Option{WithShards(8), WithControllerShards(16)}, "ControllerShards(16)"},
+		{[]Option{WithEviction(EvictionConfig{LevelSize: 100})}, "LevelSize(100)"},
+		{[]Option{WithOptions(Options{LockFreeReads: true}), WithSlidingExpiration()}, "SlidingExpiration"},
+	}
+	for k, v := range cases {
+		err := New(v.opts...)
+		if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), v.msg) {
+			t.Error("失败", k, err)
+		}
+	}
+}
+
+func TestNew_JSON(t *testing.T) {
+	data := []byte(`{
+		"obj_max_count": 50000,
+		"shards": 16,
+		"capacity_policy": 1,
+		"default_ttl": 60,
+		"eviction": {"level_size": 8, "level_rest_step": 30},
+		"admission": {"topics": {"scan": true}}
+	}`)
+
+	var opts Options
+	if err := json.Unmarshal(data, &opts); err != nil {
+		t.Fatal(err)
+	}
+	// 之后的配置项覆盖配置文件中的值
+	var o Options
+	for _, opt := range []Option{WithOptions(opts), WithShards(32), WithCodec(GobCodec{})} {
+		opt(&o)
+	}
+	if o.ObjMaxCount != 5e4 || o.Shards != 32 || o.CapacityPolicy != CapacityEvict || o.DefaultTTL != 60 ||
+		o.Eviction.LevelSize != 8 || o.Eviction.LevelRestStep != 30 || !o.Admission.Topics["scan"] || o.Codec == nil {
+		t.Error("失败1", o)
+	}
+
+	// 接口、函数类型的字段不输出
+	data, err := json.Marshal(Options{Codec: GobCodec{}, OnEvict: func(uint64, interface{}) {}})
+	if err != nil || bytes.Contains(data, []byte("Codec")) || !bytes.Contains(data, []byte(`"obj_max_count":0`)) {
+		t.Error("失败2", string(data), err)
+	}
+}
+
+func TestConfig_RoundTrip(t *testing.T) {
+	withNew(t, func() {
+		opts, err := Config()
+		if err != nil || *opts.Eviction.DestroyStability != 0 || *opts.Eviction.AdaptiveHysteresis != 0 ||
+			*opts.Eviction.RescueStability != 700 {
+			t.Fatal("失败1", err)
+		}
+
+		// 经过JSON编解码后再次初始化，配置不变（0不会被当作默认值）
+		data, err := json.Marshal(opts)
+		if err != nil || !bytes.Contains(data, []byte(`"destroy_stability":0`)) {
+			t.Fatal("失败2", string(data), err)
+		}
+		var decoded Options
+		if err = json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal("失败3", err)
+		}
+		withNew(t, func() {
+			again, err := Config()
+			if err != nil || !reflect.DeepEqual(again, opts) {
+				t.Error("失败4", again, opts)
+			}
+		}, WithOptions(decoded))
+
+		// 修改返回的配置不影响正在使用的配置
+		*opts.Eviction.DestroyStability = 300
+		if current, _ := Config(); *current.Eviction.DestroyStability != 0 {
+			t.Error("失败5")
+		}
+	},
+		WithCapacity(2e4),
+		WithDefaultTTL(60),
+		WithEviction(EvictionConfig{DestroyStability: Permille(0), AdaptiveHysteresis: Permille(0)}),
+	)
+}
+
+func TestNew_Total(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	var logs bytes.Buffer
+
+	var lock sync.Mutex
+	evicted := make(map[uint64]interface{})
+	expired := make(map[uint64]interface{})
+	record := func(m map[uint64]interface{}) RemoveFunc {
+		return func(hash uint64, obj interface{}) {
+			lock.Lock()
+			m[hash] = obj
+			lock.Unlock()
+		}
+	}
+
+	withNew(t, func() {
+		if New() != ErrAlreadyInitialized {
+			t.Error("失败1")
+		}
+
+		// 生效的配置
+		opts, err := Config()
+		if err != nil || opts.ObjMaxCount != 1e4 || opts.Shards != 4 || opts.ControllerShards != 2 || opts.MaxBytes != 1000 ||
+			opts.DefaultTTL != 100 || opts.MaxValueSize != defaultMaxValueSize ||
+			!reflect.DeepEqual(opts.Eviction, DefaultEvictionConfig().WithDefaults()) {
+			t.Error("失败2", opts, err)
+		}
+
+		// 默认的过期时间，NoExpire不过期
+		_ = Set([]byte("ttl1"), []byte("v"), 0)
+		_ = Set([]byte("ttl2"), []byte("v"), NoExpire)
+		hash := KeyHash("", []byte("ttl1"))
+		if _, _, expire, _ := c.segments.Of(hash).GetValue(hash); expire != internal.Now()+100 {
+			t.Error("失败3", expire)
+		}
+		hash = KeyHash("", []byte("ttl2"))
+		if _, _, expire, _ := c.segments.Of(hash).GetValue(hash); expire != math.MaxUint32 {
+			t.Error("失败4", expire)
+		}
+
+		// 超出MaxBytes时同步淘汰
+		value := make([]byte, 100)
+		for i := 0; i < 20; i++ {
+			value[0] = byte(i)
+			if err := SetInt(int64(i), value, 0); err != nil {
+				t.Error("失败5", i, err)
+			}
+			if c.segments.Bytes() > 1000 {
+				t.Error("失败6", c.segments.Bytes())
+			}
+		}
+		if SetInt(100, make([]byte, 1001), 0) != ErrTooLarge {
+			t.Error("失败7")
+		}
+		lock.Lock()
+		if len(evicted) == 0 {
+			t.Error("失败8")
+		}
+		for hash, obj := range evicted {
+			if c.segments.Of(hash).Has(hash) || len(obj.([]byte)) == 0 {
+				t.Error("失败9")
+			}
+		}
+		lock.Unlock()
+
+		// 读取时发现过期
+		_ = Set([]byte("expire1"), []byte("expired"), 5)
+		clock.add(10 * time.Second)
+		if _, ok := Get([]byte("expire1")); ok {
+			t.Error("失败10")
+		}
+		lock.Lock()
+		if obj, ok := expired[KeyHash("", []byte("expire1"))]; !ok || string(obj.([]byte)) != "expired" {
+			t.Error("失败11", expired)
+		}
+		lock.Unlock()
+
+		// 清除剩余的对象
+		_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+	},
+		WithCapacity(1e4),
+		WithShards(4),
+		WithControllerShards(2),
+		WithCodec(BytesCodec{}),
+		WithMaxBytes(1000),
+		WithDefaultTTL(100),
+		WithClock(clock),
+		WithLogger(log.New(&logs, "", 0)),
+		WithOnEvict(record(evicted)),
+		WithOnExpire(record(expired)),
+	)
+
+	if _, err := Config(); err != nil {
+		t.Error("失败12", err)
+	}
+}
+
+func TestNew_TTL(t *testing.T) {
+	// 获取对象的过期时间
+	expireOf := func(topic string, key string) uint32 {
+		hash := KeyHash(topic, []byte(key))
+		_, _, expire, _ := c.segments.Of(hash).GetValue(hash)
+		return expire
+	}
+
+	withNew(t, func() {
+		now := internal.Now()
+		used := make(map[uint32]bool)
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprint("jitter", i)
+			_ = Set([]byte(key), i, 0)
+			expire := expireOf("", key)
+			if expire < now+90 || expire > internal.Now()+100 {
+				t.Fatal("失败1", expire-now)
+			}
+			used[expire] = true
+		}
+		if len(used) < 5 {
+			t.Error("失败2", len(used))
+		}
+
+		// topic的默认过期时间，不抖动
+		_ = SetByTopic("session", []byte("s1"), 1, 0)
+		if expire := expireOf("session", "s1"); expire < now+30 || expire > internal.Now()+30 {
+			t.Error("失败3", expire-now)
+		}
+		_ = SetByTopic("static", []byte("s2"), 1, 0)
+		if expireOf("static", "s2") != math.MaxUint32 {
+			t.Error("失败4")
+		}
+		// 指定过期时间时仍然按topic抖动
+		_ = SetByTopic("session", []byte("s3"), 1, 1000)
+		if expire := expireOf("session", "s3"); expire < now+1000 {
+			t.Error("失败5", expire-now)
+		}
+
+		// 运行时修改，零值恢复为缓存的配置
+		if err := SetTopicTTL("session", TTLConfig{Jitter: 200}); !errors.Is(err, ErrInvalidConfig) {
+			t.Error("失败6", err)
+		}
+		if SetTopicTTL("session", TTLConfig{}) != nil || SetTopicTTL("", TTLConfig{Default: 10, Jitter: NoJitter}) != nil {
+			t.Error("失败7")
+		}
+		_ = SetByTopic("session", []byte("s4"), 1, 0)
+		if expire := expireOf("session", "s4"); expire < now+90 || expire > internal.Now()+100 {
+			t.Error("失败8", expire-now)
+		}
+		SetDirect([]byte("d1"), 1, 0)
+		if expire := expireOf("", "d1"); expire < now+10 || expire > internal.Now()+10 {
+			t.Error("失败9", expire-now)
+		}
+
+		_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+	},
+		WithDefaultTTL(100),
+		WithTTLJitter(10),
+		WithTopicTTL("session", TTLConfig{Default: 30, Jitter: NoJitter}),
+		WithTopicTTL("static", TTLConfig{Default: NoExpire}),
+	)
+
+	if err := New(WithTopicTTL("a", TTLConfig{Default: -2})); !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), `TopicTTL["a"]`) {
+		t.Error("失败10", err)
+	}
+}
+
+func TestNew_MaxTopics(t *testing.T) {
+	withNew(t, func() {
+		// 默认topic不占用编号
+		if SetByTopic("t1", []byte("k"), 1, 0) != nil || SetByTopic("t2", []byte("k"), 2, 0) != nil || Set([]byte("k"), 0, 0) != nil {
+			t.Error("失败1")
+		}
+		if SetByTopic("t3", []byte("k"), 3, 0) != ErrRejected || SetTopicTTL("t3", TTLConfig{Default: 10}) != ErrRejected {
+			t.Error("失败2")
+		}
+		SetDirectByTopic("t3", []byte("k"), 3, 0)
+		if _, ok := GetDirectByTopic("t3", []byte("k")); ok {
+			t.Error("失败3")
+		}
+
+		// 编号不回收，已经使用的topic仍然可以存储
+		DropTopic("t1")
+		if SetByTopic("t3", []byte("k"), 3, 0) != ErrRejected || SetByTopic("t1", []byte("k"), 1, 0) != nil {
+			t.Error("失败4")
+		}
+
+		_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+	},
+		WithOptions(Options{MaxTopics: 2}),
+	)
+
+	if err := New(WithOptions(Options{MaxTopics: -1})); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("失败5", err)
+	}
+	if err := New(WithOptions(Options{MaxTopics: 1}), WithTopicTTL("a", TTLConfig{Default: 1}), WithTopicTTL("b", TTLConfig{Default: 1})); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("失败6", err)
+	}
+}
+
+func TestNew_IntakeEvict(t *testing.T) {
+	withNew(t, func() {
+		// 离最大缓存数量还远时，积压达到上限只等待controller处理，写入高峰不淘汰对象
+		const count = 5000
+		for i := 0; i < count; i++ {
+			if err := SetInt(int64(i), i, 0); err != nil {
+				t.Fatal("失败1", i, err)
+			}
+		}
+		for i := 0; i < count; i++ {
+			if _, ok := GetInt(int64(i)); !ok {
+				t.Fatal("失败2", i)
+			}
+		}
+		if GetStats().IntakeOverflow == 0 {
+			t.Error("失败3")
+		}
+
+		_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+	},
+		WithOptions(Options{ObjMaxCount: 1e4, Shards: 1, ControllerShards: 1, IntakeLimit: 8, IntakePolicy: IntakeEvict}),
+	)
+}
@@ -0,0 +1,107 @@
+package objectCache
+
+import (
+	"context"
+	"errors"
+	"objectCache/internal"
+)
+
+// 以下为返回错误、接受context.Context的接口：缓存没有初始化、已经关闭时返回ErrNotInitialized、ErrClosed，而不是panic或者静默失败；
+// 可能阻塞的操作（积压达到上限时等待、严格容量模式下的同步淘汰、加载对象）在ctx结束时返回ctx.Err()
+
+// Loader 加载缓存中不存在的对象，返回对象和过期时间（单位是秒，为0则不过期）
+type Loader func(ctx context.Context) (obj interface{}, expireSecond int, err error)
+
+// loadCall 正在进行的加载，同一个对象同时只加载一次，其他调用者等待结果
+type loadCall struct {
+	done chan struct{}
+	obj  interface{}
+	err  error
+}
+
+// topicKey 拼接key和topic，topic为空则使用默认 _DefaultTopic_
+func topicKey(topic string, key []byte) []byte {
+	if topic == "" {
+		return append(key, defaultTopic...)
+	}
+	return append(key, internal.String2Bytes(topic)...)
+}
+
+// checkState 检查缓存是否可以使用
+func checkState() (err error) {
+	if c == nil {
+		return ErrNotInitialized
+	}
+	if c.isClosed() {
+		return ErrClosed
+	}
+	return nil
+}
+
+// SetContext 缓存字符切片为键值的对象。使用默认 _DefaultTopic_
+// 返回ErrNotInitialized、ErrClosed、ErrRejected、ErrTooLarge，阻塞期间ctx结束则返回ctx.Err()
+func SetContext(ctx context.Context, key []byte, obj interface{}, expireSecond int) (err error) {
+	return SetByTopicContext(ctx, "", key, obj, expireSecond)
+}
+
+// SetByTopicContext 缓存字符切片为键值的对象。topic为空则使用默认 _DefaultTopic_，返回的错误同SetContext()
+func SetByTopicContext(ctx context.Context, topic string, key []byte, obj interface{}, expireSecond int) (err error) {
+	return set(ctx, topic, topicKey(topic, key), obj, expireSecond, PriorityNormal)
+}
+
+// Lookup 根据字符切片型键值获取对象。topic为空则使用默认 _DefaultTopic_
+// ok为false并且err为nil说明对象不存在；缓存没有初始化、已经关闭时返回ErrNotInitialized、ErrClosed
+func Lookup(topic string, key []byte) (obj interface{}, ok bool, err error) {
+	if err = checkState(); err != nil {
+		return nil, false, err
+	}
+	obj, ok = get(topicKey(topic, key))
+	return obj, ok, nil
+}
+
+// GetOrLoad 获取对象，不存在则调用loader加载并存入缓存。topic为空则使用默认 _DefaultTopic_
+// 同一个对象同时只加载一次，其他调用者等待第一个调用者的结果（包括其ctx结束的错误）。
+// 加载的对象被拒绝存入（ErrRejected）不影响本次返回；ctx结束时返回ctx.Err()，正在进行的加载不会被中断
+func GetOrLoad(ctx context.Context, topic string, key []byte, loader Loader) (obj interface{}, err error) {
+	if err = checkState(); err != nil {
+		return nil, err
+	}
+
+	key = topicKey(topic, key)
+	hashVal := internal.HashFunc(key)
+	// 与get()相同计入准入策略的访问频率
+	if c.sketch != nil {
+		c.sketch.Increment(hashVal)
+	}
+	if obj, ok := c.load(hashVal, c.segments.Index(hashVal)); ok {
+		return obj, nil
+	}
+
+	call := &loadCall{done: make(chan struct{})}
+	if v, loaded := c.loads.LoadOrStore(hashVal, call); loaded {
+		call = v.(*loadCall)
+		select {
+		case <-call.done:
+			return call.obj, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	defer func() {
+		c.loads.Delete(hashVal)
+		close(call.done)
+	}()
+
+	obj, expireSecond, err := loader(ctx)
+	if err == nil {
+		if err = set(ctx, topic, key, obj, expireSecond, PriorityNormal); errors.Is(err, ErrRejected) {
+			err = nil
+		}
+	}
+	if err != nil {
+		obj = nil
+	}
+	call.obj, call.err = obj, err
+	return obj, err
+}
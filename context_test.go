@@ -0,0 +1,173 @@
+package objectCache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContext_Errors(t *testing.T) {
+	InitDefaultObjectCache()
+
+	// 没有初始化
+	saved := c
+	c = nil
+	if SetContext(context.Background(), []byte("ctx1"), 1, 0) != ErrNotInitialized || Set([]byte("ctx1"), 1, 0) != ErrNotInitialized {
+		t.Error("失败1")
+	}
+	if _, _, err := Lookup("", []byte("ctx1")); err != ErrNotInitialized {
+		t.Error("失败2")
+	}
+	// 没有初始化时旧的接口返回零值，不panic
+	SetDirect([]byte("ctx1"), 1, 0)
+	if _, ok := Get([]byte("ctx1")); ok || Del([]byte("ctx1")) || GetObjCount() != 0 || GetStats().TotalCount != 0 {
+		t.Error("失败9")
+	}
+	if _, ok := GetDirect([]byte("ctx1")); ok || DelDirect([]byte("ctx1")) || Pin([]byte("ctx1")) || DropTopic("ctx") != 0 {
+		t.Error("失败10")
+	}
+	if GetQueueCount() != "" || GetInvalidationErrors() != 0 || SetTopicAdmission("ctx", true) != ErrNotInitialized {
+		t.Error("失败11")
+	}
+	c = saved
+
+	// 配置不合法
+	if _, err := (Options{Shards: 3}).normalize(); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("失败3", err)
+	}
+	if _, err := (Options{Eviction: EvictionConfig{StableLower: Permille(2000), StableUpper: 1000}}).normalize(); !errors.Is(err, ErrInvalidConfig) {
+		t.Error("失败4", err)
+	}
+
+	// 编码后对象太大
+	cc := &objectCache{codec: BytesCodec{}, maxValueSize: 4}
+	if _, err := cc.encode([]byte("12345")); err != ErrTooLarge {
+		t.Error("失败5", err)
+	}
+	if _, err := cc.encode([]byte("1234")); err != nil {
+		t.Error("失败6", err)
+	}
+	// 编码失败的错误可以用errors.Is判断
+	cc.codec = failCodec{}
+	if _, err := cc.encode(1); !errors.Is(err, errFailCodec) {
+		t.Error("失败12", err)
+	}
+
+	if SetByTopicContext(context.Background(), "ctxTopic", []byte("ctx2"), 2, 0) != nil {
+		t.Error("失败7")
+	}
+	if obj, ok, err := Lookup("ctxTopic", []byte("ctx2")); !ok || err != nil || obj.(int) != 2 {
+		t.Error("失败8")
+	}
+
+	// 清除剩余的对象，不影响其他测试
+	_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+}
+
+func TestContext_GetOrLoad(t *testing.T) {
+	InitDefaultObjectCache()
+
+	// 同时加载同一个对象只调用一次loader
+	var calls int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (obj interface{}, expireSecond int, err error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "loaded", 0, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if obj, err := GetOrLoad(context.Background(), "ctxLoad", []byte("k1"), loader); err != nil || obj != "loaded" {
+				t.Error("失败1", obj, err)
+			}
+		}()
+	}
+	time.Sleep(time.Millisecond * 10)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Error("失败2", calls)
+	}
+
+	// 已经存入缓存
+	if obj, ok := GetByTopic("ctxLoad", []byte("k1")); !ok || obj != "loaded" {
+		t.Error("失败3")
+	}
+
+	// 加载失败不存入缓存
+	loadErr := errors.New("load failed")
+	_, err := GetOrLoad(context.Background(), "ctxLoad", []byte("k2"), func(ctx context.Context) (interface{}, int, error) {
+		return nil, 0, loadErr
+	})
+	if err != loadErr {
+		t.Error("失败4", err)
+	}
+	if _, ok := GetByTopic("ctxLoad", []byte("k2")); ok {
+		t.Error("失败5")
+	}
+
+	// 等待其他调用者加载时ctx结束
+	block := make(chan struct{})
+	loaded := make(chan struct{})
+	go func() {
+		defer close(loaded)
+		_, _ = GetOrLoad(context.Background(), "ctxLoad", []byte("k3"), func(ctx context.Context) (interface{}, int, error) {
+			<-block
+			return 3, 0, nil
+		})
+	}()
+	time.Sleep(time.Millisecond * 10)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if _, err = GetOrLoad(ctx, "ctxLoad", []byte("k3"), loader); err != context.DeadlineExceeded {
+		t.Error("失败6", err)
+	}
+	close(block)
+	<-loaded
+
+	// 清除剩余的对象，不影响其他测试
+	_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+}
+
+var errFailCodec = errors.New("fail codec")
+
+// failCodec 编码总是失败
+type failCodec struct{}
+
+func (failCodec) Marshal(obj interface{}) (data []byte, err error) {
+	return nil, errFailCodec
+}
+
+func (failCodec) Unmarshal(data []byte) (obj interface{}, err error) {
+	return nil, errFailCodec
+}
+
+func TestContext_GetOrLoadAdmission(t *testing.T) {
+	withNew(t, func() {
+		// 与Get()相同计入准入策略的访问频率
+		hash := KeyHash("ctxLoad", []byte("k1"))
+		loader := func(ctx context.Context) (interface{}, int, error) {
+			return 1, 0, nil
+		}
+		before := c.sketch.Estimate(hash)
+		for i := 0; i < 4; i++ {
+			if _, err := GetOrLoad(context.Background(), "ctxLoad", []byte("k1"), loader); err != nil {
+				t.Fatal("失败1", err)
+			}
+		}
+		// 加载时存入（准入判断）计入一次，4次读取各计入一次
+		if after := c.sketch.Estimate(hash); after != before+5 {
+			t.Error("失败2", before, after)
+		}
+	},
+		WithOptions(Options{Admission: AdmissionConfig{Enabled: true}}),
+	)
+}
@@ -2,65 +2,64 @@ package objectCache
 
 import (
 	"encoding/binary"
-	"math"
 	"objectCache/internal"
-	"objectCache/internal/storage"
-	"time"
 )
 
-// setDirect 不纳入淘汰管理，直接存储
-func setDirect(key []byte, obj interface{}, expireSecond int) {
+// setDirect 不纳入淘汰管理，直接存储。序列化存储模式下编码失败或者字节区超过最大大小则删除原有的对象。
+// 缓存没有初始化或者已经关闭、topic的个数达到上限（Options.MaxTopics）时不存储
+func setDirect(topic string, key []byte, obj interface{}, expireSecond int) {
+	if checkState() != nil {
+		return
+	}
 
 	hashVal := internal.HashFunc(key)
-	segID := hashVal % storage.MaxSegmentSize
+	segID := c.segments.Index(hashVal)
 
-	n := c.nodeCache.GetNode()
-	ok := c.segments[segID].Set(obj, hashVal, expireSecond, n)
-	if !ok {
-		c.nodeCache.SaveNode(n)
+	obj, err := c.encode(obj)
+	if err != nil {
+		c.delHash(hashVal, segID)
+		return
 	}
-	return
-}
 
-// getDirect 不纳入淘汰管理，直接获取
-func getDirect(key []byte) (obj interface{}, ok bool) {
-	hashVal := internal.HashFunc(key)
-	segID := hashVal % storage.MaxSegmentSize
-	node, ok := c.segments[segID].Get(hashVal)
+	topicID, ok := c.topicID(topic)
 	if !ok {
-		return nil, false
+		// topic的个数达到上限，本地不可能存在此topic的对象
+		return
+	}
+	if n, _ := c.segments.List[segID].SetDirect(obj, hashVal, c.ttl(topic, expireSecond), topicID); n == nil {
+		// 字节区超过最大大小，与编码失败相同
+		c.delHash(hashVal, segID)
+		return
 	}
+	c.publishDel(hashVal)
+}
 
-	if node.Expire != math.MaxUint32 && uint32(time.Now().Unix()) > node.Expire {
-		n, ok := c.segments[segID].Del(hashVal)
-		if ok {
-			c.nodeCache.SaveNode(n)
-		}
+// getDirect 不纳入淘汰管理，直接获取。缓存没有初始化时返回false
+func getDirect(key []byte) (obj interface{}, ok bool) {
+	if c == nil {
 		return nil, false
 	}
-
-	return node.Obj, ok
+	hashVal := internal.HashFunc(key)
+	segID := c.segments.Index(hashVal)
+	return c.load(hashVal, segID)
 }
 
-// delDirect 不纳入淘汰管理，直接删除
+// delDirect 不纳入淘汰管理，直接删除。缓存没有初始化时返回false
 func delDirect(key []byte) (ok bool) {
-	hashVal := internal.HashFunc(key)
-	segID := hashVal % storage.MaxSegmentSize
-	var n *internal.Node
-
-	n, ok = c.segments[segID].Del(hashVal)
-	if ok {
-		c.nodeCache.SaveNode(n)
+	if c == nil {
+		return false
 	}
+	hashVal := internal.HashFunc(key)
+	segID := c.segments.Index(hashVal)
 
-	return ok
+	return c.delHash(hashVal, segID)
 }
 
 // set 缓存字符切片为键值的对象，不纳入淘汰管理。使用默认 _DefaultTopic_
 // key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期
 func SetDirect(key []byte, obj interface{}, expireSecond int) {
 	key = append(key, defaultTopic...)
-	setDirect(key, obj, expireSecond)
+	setDirect("", key, obj, expireSecond)
 }
 
 // SetInt 缓存一个以int型KEY的对象，不纳入淘汰管理，不纳入淘汰管理。使用默认 _DefaultTopic_
@@ -111,7 +110,7 @@ func SetDirectByTopic(topic string, key []byte, obj interface{}, expireSecond in
 		key = append(key, internal.String2Bytes(topic)...)
 	}
 
-	setDirect(key, obj, expireSecond)
+	setDirect(topic, key, obj, expireSecond)
 }
 
 // SetInt 缓存一个以int型KEY的对象，不纳入淘汰管理，当对象已经存在返回false。topic为空则使用默认 _DefaultTopic_
@@ -126,7 +125,7 @@ func SetIntDirectByTopic(topic string, key int64, obj interface{}, expireSecond
 		hashKey = append(bKey[:], internal.String2Bytes(topic)...)
 	}
 
-	setDirect(hashKey, obj, expireSecond)
+	setDirect(topic, hashKey, obj, expireSecond)
 }
 
 // Get 根据字符切片型键值获取对象，不纳入淘汰管理，当对象不存在返回false。topic为空则使用默认 _DefaultTopic_
@@ -0,0 +1,22 @@
+package objectCache
+
+import (
+	"errors"
+	"objectCache/internal"
+)
+
+var (
+	// ErrRejected 对象被拒绝存入（如严格容量模式下对象数量已经达到最大缓存数量，序列化存储模式下分段的字节区超过最大大小4GB，
+	// topic的个数达到Options.MaxTopics）
+	ErrRejected = errors.New("objectCache: 对象被拒绝存入")
+	// ErrNotInitialized 缓存还没有初始化（InitObjectCache()等）
+	ErrNotInitialized = errors.New("objectCache: 缓存没有初始化")
+	// ErrClosed 缓存已经关闭（Close()）
+	ErrClosed = internal.ErrClosed
+	// ErrTooLarge 序列化存储模式下编码后的对象超过Options.MaxValueSize
+	ErrTooLarge = errors.New("objectCache: 对象太大")
+	// ErrAlreadyInitialized 缓存已经初始化，New()不能重复调用
+	ErrAlreadyInitialized = errors.New("objectCache: 缓存已经初始化")
+	// ErrInvalidConfig 配置不合法，返回的错误包装了此错误，使用errors.Is()判断
+	ErrInvalidConfig = errors.New("objectCache: 配置不合法")
+)
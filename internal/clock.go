@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock 时间来源，测试时可以注入自己控制的时间
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock 系统时间
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// 粗粒度的时钟：淘汰模型、过期时间都以秒为单位，热点路径（Set、Get）读取由后台协程每秒更新的时间，不需要每次调用time.Now()
+var (
+	// 当前时间（Unix time，单位为秒）
+	coarseNow uint32
+	// 后台协程是否已经启动
+	clockStarted uint32
+	clockOnce    sync.Once
+
+	clockLock sync.Mutex
+	clock     Clock = systemClock{}
+
+	// 当前时间变化的通知（chan struct{}），时间变化时关闭并替换为新的channel
+	clockChanged atomic.Value
+)
+
+func init() {
+	clockChanged.Store(make(chan struct{}))
+}
+
+// Now 获取粗粒度的当前时间（Unix time，单位为秒），第一次调用时启动后台更新的协程
+func Now() uint32 {
+	if atomic.LoadUint32(&clockStarted) == 0 {
+		clockOnce.Do(startClock)
+	}
+	return atomic.LoadUint32(&coarseNow)
+}
+
+// SetClock 设置时间来源，为nil则恢复为系统时间。设置后立即更新当前时间
+func SetClock(c Clock) {
+	if c == nil {
+		c = systemClock{}
+	}
+	clockLock.Lock()
+	clock = c
+	clockLock.Unlock()
+	RefreshClock()
+}
+
+// ClockChanged 返回当前时间（Now()）下一次变化时被关闭的channel。先获取channel再读取Now()，不会错过变化
+func ClockChanged() <-chan struct{} {
+	return clockChanged.Load().(chan struct{})
+}
+
+// RefreshClock 立即从时间来源更新当前时间，注入的时间被修改后调用，不需要等待后台协程更新
+func RefreshClock() {
+	clockLock.Lock()
+	now := uint32(clock.Now().Unix())
+	if atomic.SwapUint32(&coarseNow, now) != now {
+		ch := clockChanged.Load().(chan struct{})
+		clockChanged.Store(make(chan struct{}))
+		close(ch)
+	}
+	clockLock.Unlock()
+}
+
+// startClock 启动后台协程：每次在系统时间的下一个整秒时更新，更新的延迟只有协程调度的时间
+func startClock() {
+	RefreshClock()
+	atomic.StoreUint32(&clockStarted, 1)
+
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Second).Add(time.Second).Sub(now))
+			RefreshClock()
+		}
+	}()
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestClock_Now(t *testing.T) {
+	if now := uint32(time.Now().Unix()); Now() > now || now-Now() > 1 {
+		t.Fatal("失败1", Now(), now)
+	}
+
+	tc := &testClock{now: time.Unix(1000, 0)}
+	SetClock(tc)
+	defer SetClock(nil)
+	if Now() != 1000 {
+		t.Error("失败2", Now())
+	}
+
+	// 注入的时间被修改后，由后台协程或者RefreshClock()更新，并通知等待时间变化的协程
+	changed := ClockChanged()
+	tc.now = time.Unix(1010, 0)
+	RefreshClock()
+	if Now() != 1010 {
+		t.Error("失败3", Now())
+	}
+	select {
+	case <-changed:
+	default:
+		t.Error("失败3.1")
+	}
+	// 时间没有变化不通知
+	changed = ClockChanged()
+	RefreshClock()
+	select {
+	case <-changed:
+		t.Error("失败3.2")
+	default:
+	}
+
+	SetClock(nil)
+	if now := uint32(time.Now().Unix()); now-Now() > 1 {
+		t.Error("失败4", Now(), now)
+	}
+}
+
+func BenchmarkClock_Now(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = Now()
+		}
+	})
+}
+
+func BenchmarkClock_TimeNow(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = uint32(time.Now().Unix())
+		}
+	})
+}
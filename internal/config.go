@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"fmt"
+)
+
+// restQueue个数的上限
+const maxLevelSize = 64
+
+// EvictionConfig 淘汰模型的参数。除LevelSize、LevelRestStep、NodeUnitRestTime外，其余阈值都是千分比（以ScaleFactor为基准）。
+// 字段为0则使用默认值；0也是合法值的千分比字段（如DestroyStability为0则不因稳定性低移入destroyQueue）为指针，nil则使用默认值，
+// 使用Permille()设置。填充默认值后的配置（如Config()返回的）再次使用时结果相同，JSON、YAML中省略或者为null的字段使用默认值
+type EvictionConfig struct {
+	// restQueue的个数
+	LevelSize int `json:"level_size" yaml:"level_size"`
+	// restQueue队列休息时间步长（单位为秒），第n级restQueue的休息时间为 LevelRestStep*(n+1)，initialQueue、destroyQueue
+	// 的休息时间为 LevelRestStep
+	LevelRestStep uint32 `json:"level_rest_step" yaml:"level_rest_step"`
+	// 被访问的单位时间（单位为秒），在单位时间内访问的次数即为访问频率
+	NodeUnitRestTime uint32 `json:"node_unit_rest_time" yaml:"node_unit_rest_time"`
+
+	// 稳定性低于此值，并且访问频率达到淘汰比例，则node移入destroyQueue（默认500）
+	DestroyStability *uint64 `json:"destroy_stability" yaml:"destroy_stability"`
+	// 稳定性在[StableLower, StableUpper]之间，则node升一级（默认900、1100）
+	StableLower *uint64 `json:"stable_lower" yaml:"stable_lower"`
+	StableUpper uint64  `json:"stable_upper" yaml:"stable_upper"`
+	// 稳定性低于此值则node降级，每多低100降一级（默认800）
+	DowngradeStability *uint64 `json:"downgrade_stability" yaml:"downgrade_stability"`
+	// 对象数量达到最大缓存数量的此比例后才开始淘汰（默认950）
+	EliminateThreshold *uint64 `json:"eliminate_threshold" yaml:"eliminate_threshold"`
+	// destroyQueue中的node，当没有待淘汰的数量时，稳定性达到此值则重新放入restQueue（默认700）
+	RescueStability *uint64 `json:"rescue_stability" yaml:"rescue_stability"`
+
+	// 关闭restQueue休息时间的动态调整
+	DisableAdaptive bool `json:"disable_adaptive" yaml:"disable_adaptive"`
+	// 动态调整后休息时间步长的范围（单位为秒，默认为LevelRestStep的1/2到4倍）
+	MinRestStep uint32 `json:"min_rest_step" yaml:"min_rest_step"`
+	MaxRestStep uint32 `json:"max_rest_step" yaml:"max_rest_step"`
+	// 对象数量占最大缓存数量的比例在[AdaptiveLower, AdaptiveUpper]之间时使用LevelRestStep，超出则按比例调整（默认800、1200）
+	AdaptiveLower *uint64 `json:"adaptive_lower" yaml:"adaptive_lower"`
+	AdaptiveUpper uint64  `json:"adaptive_upper" yaml:"adaptive_upper"`
+	// 调整幅度小于当前步长的此比例则不调整，避免对象数量在边界附近波动时反复调整（默认100）
+	AdaptiveHysteresis *uint64 `json:"adaptive_hysteresis" yaml:"adaptive_hysteresis"`
+}
+
+// DefaultEvictionConfig 返回默认的淘汰模型参数
+func DefaultEvictionConfig() EvictionConfig {
+	return EvictionConfig{
+		LevelSize:          LevelSize,
+		LevelRestStep:      uint32(LevelRestStep),
+		NodeUnitRestTime:   NodeUnitRestTime,
+		DestroyStability:   Permille(500),
+		StableLower:        Permille(900),
+		StableUpper:        1100,
+		DowngradeStability: Permille(800),
+		EliminateThreshold: Permille(950),
+		RescueStability:    Permille(700),
+		MinRestStep:        uint32(LevelRestStep / 2),
+		MaxRestStep:        uint32(LevelRestStep * 4),
+		AdaptiveLower:      Permille(800),
+		AdaptiveUpper:      1200,
+		AdaptiveHysteresis: Permille(100),
+	}
+}
+
+// Permille 返回指向v的指针，用于设置EvictionConfig中0也是合法值的千分比字段（如DestroyStability）
+func Permille(v uint64) *uint64 {
+	return &v
+}
+
+// WithDefaults 将值为0的字段、值为nil的千分比字段设置为默认值。千分比字段返回新的指针，不与e共享
+func (e EvictionConfig) WithDefaults() EvictionConfig {
+	d := DefaultEvictionConfig()
+	if e.LevelSize == 0 {
+		e.LevelSize = d.LevelSize
+	}
+	if e.LevelRestStep == 0 {
+		e.LevelRestStep = d.LevelRestStep
+	}
+	if e.NodeUnitRestTime == 0 {
+		e.NodeUnitRestTime = d.NodeUnitRestTime
+	}
+	e.DestroyStability = orDefault(e.DestroyStability, d.DestroyStability)
+	e.StableLower = orDefault(e.StableLower, d.StableLower)
+	if e.StableUpper == 0 {
+		e.StableUpper = d.StableUpper
+	}
+	e.DowngradeStability = orDefault(e.DowngradeStability, d.DowngradeStability)
+	e.EliminateThreshold = orDefault(e.EliminateThreshold, d.EliminateThreshold)
+	e.RescueStability = orDefault(e.RescueStability, d.RescueStability)
+	// 步长范围跟随LevelRestStep
+	if e.MinRestStep == 0 {
+		e.MinRestStep = e.LevelRestStep / 2
+		if e.MinRestStep < e.NodeUnitRestTime {
+			e.MinRestStep = e.NodeUnitRestTime
+		}
+	}
+	if e.MaxRestStep == 0 {
+		e.MaxRestStep = e.LevelRestStep * 4
+	}
+	e.AdaptiveLower = orDefault(e.AdaptiveLower, d.AdaptiveLower)
+	if e.AdaptiveUpper == 0 {
+		e.AdaptiveUpper = d.AdaptiveUpper
+	}
+	e.AdaptiveHysteresis = orDefault(e.AdaptiveHysteresis, d.AdaptiveHysteresis)
+	return e
+}
+
+// orDefault 千分比字段：nil使用默认值，返回新的指针
+func orDefault(v, def *uint64) *uint64 {
+	if v == nil {
+		v = def
+	}
+	return Permille(*v)
+}
+
+// Validate 检查参数是否合法，需要先调用WithDefaults()（千分比字段不能为nil）
+func (e EvictionConfig) Validate() (err error) {
+	if e.DestroyStability == nil || e.StableLower == nil || e.DowngradeStability == nil || e.EliminateThreshold == nil ||
+		e.RescueStability == nil || e.AdaptiveLower == nil || e.AdaptiveHysteresis == nil {
+		return fmt.Errorf("千分比字段没有填充默认值")
+	}
+	if e.LevelSize < 1 || e.LevelSize > maxLevelSize {
+		return fmt.Errorf("LevelSize(%d)的范围为[1, %d]", e.LevelSize, maxLevelSize)
+	}
+	if e.NodeUnitRestTime == 0 {
+		return fmt.Errorf("NodeUnitRestTime不能为0")
+	}
+	if e.LevelRestStep < e.NodeUnitRestTime {
+		return fmt.Errorf("LevelRestStep(%d)不能小于NodeUnitRestTime(%d)", e.LevelRestStep, e.NodeUnitRestTime)
+	}
+	if uint64(e.LevelRestStep)*uint64(e.LevelSize) > 1<<31 {
+		return fmt.Errorf("LevelRestStep(%d)*LevelSize(%d)过大", e.LevelRestStep, e.LevelSize)
+	}
+	if *e.StableLower > ScaleFactor || e.StableUpper < ScaleFactor {
+		return fmt.Errorf("稳定区间[%d, %d]必须包含%d", *e.StableLower, e.StableUpper, ScaleFactor)
+	}
+	if *e.DowngradeStability > *e.StableLower {
+		return fmt.Errorf("DowngradeStability(%d)不能大于StableLower(%d)", *e.DowngradeStability, *e.StableLower)
+	}
+	if *e.DestroyStability > *e.DowngradeStability {
+		return fmt.Errorf("DestroyStability(%d)不能大于DowngradeStability(%d)", *e.DestroyStability, *e.DowngradeStability)
+	}
+	if *e.EliminateThreshold > ScaleFactor {
+		return fmt.Errorf("EliminateThreshold(%d)不能大于%d", *e.EliminateThreshold, ScaleFactor)
+	}
+	if *e.RescueStability > ScaleFactor {
+		return fmt.Errorf("RescueStability(%d)不能大于%d", *e.RescueStability, ScaleFactor)
+	}
+	if e.MinRestStep < e.NodeUnitRestTime || e.MinRestStep > e.LevelRestStep || e.MaxRestStep < e.LevelRestStep {
+		return fmt.Errorf("步长范围[%d, %d]必须包含LevelRestStep(%d)，且不能小于NodeUnitRestTime(%d)",
+			e.MinRestStep, e.MaxRestStep, e.LevelRestStep, e.NodeUnitRestTime)
+	}
+	if uint64(e.MaxRestStep)*uint64(e.LevelSize) > 1<<31 {
+		return fmt.Errorf("MaxRestStep(%d)*LevelSize(%d)过大", e.MaxRestStep, e.LevelSize)
+	}
+	if *e.AdaptiveLower > ScaleFactor || e.AdaptiveUpper < ScaleFactor {
+		return fmt.Errorf("区间[%d, %d]必须包含%d", *e.AdaptiveLower, e.AdaptiveUpper, ScaleFactor)
+	}
+	if *e.AdaptiveHysteresis >= ScaleFactor {
+		return fmt.Errorf("AdaptiveHysteresis(%d)必须小于%d", *e.AdaptiveHysteresis, ScaleFactor)
+	}
+	return nil
+}
+
+// MaxTotalTime node、cache统计访问频率的最近期限（restQueue休息的最大时间），超过则等比例缩放
+func (e EvictionConfig) MaxTotalTime() uint32 {
+	return e.LevelRestStep * uint32(e.LevelSize)
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvictionConfig_WithDefaults(t *testing.T) {
+	cfg := EvictionConfig{LevelRestStep: 60}.WithDefaults()
+	if cfg.LevelRestStep != 60 {
+		t.Error("失败1")
+	}
+	if cfg.LevelSize != LevelSize || cfg.NodeUnitRestTime != NodeUnitRestTime {
+		t.Error("失败2")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Error("失败3", err)
+	}
+	if cfg.MaxTotalTime() != 60*LevelSize {
+		t.Error("失败4")
+	}
+
+	zero := Permille(0)
+	cfg = EvictionConfig{DestroyStability: zero, AdaptiveHysteresis: Permille(0)}.WithDefaults()
+	if *cfg.DestroyStability != 0 || *cfg.AdaptiveHysteresis != 0 || *cfg.RescueStability != 700 {
+		t.Error("失败5")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Error("失败6", err)
+	}
+
+	// 填充默认值后再次填充结果相同，不共享指针
+	again := cfg.WithDefaults()
+	if !reflect.DeepEqual(again, cfg) || again.DestroyStability == cfg.DestroyStability || cfg.DestroyStability == zero {
+		t.Error("失败7")
+	}
+	if (EvictionConfig{}).Validate() == nil {
+		t.Error("失败8")
+	}
+}
+
+func TestEvictionConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  EvictionConfig
+		ok   bool
+	}{
+		{"default", DefaultEvictionConfig(), true},
+		{"levelSize", EvictionConfig{LevelSize: maxLevelSize + 1}, false},
+		{"restStep", EvictionConfig{LevelRestStep: 5, NodeUnitRestTime: 10}, false},
+		{"stable", EvictionConfig{StableLower: Permille(1001)}, false},
+		{"downgrade", EvictionConfig{StableLower: Permille(900), DowngradeStability: Permille(950)}, false},
+		{"destroy", EvictionConfig{DestroyStability: Permille(850)}, false},
+		{"threshold", EvictionConfig{EliminateThreshold: Permille(1001)}, false},
+		{"rescue", EvictionConfig{RescueStability: Permille(1001)}, false},
+		{"restRange", EvictionConfig{MinRestStep: 700}, false},
+		{"adaptive", EvictionConfig{AdaptiveUpper: 999}, false},
+		{"explicitZero", EvictionConfig{DestroyStability: Permille(0), EliminateThreshold: Permille(0), RescueStability: Permille(0)}, true},
+		{"stableZero", EvictionConfig{StableLower: Permille(0), DowngradeStability: Permille(0), DestroyStability: Permille(0)}, true},
+		{"minute", EvictionConfig{LevelRestStep: 60, NodeUnitRestTime: 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.WithDefaults().Validate()
+			if (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -1,5 +1,6 @@
 package internal
 
+// 以下为淘汰模型参数的默认值，实际使用的参数见EvictionConfig
 const (
 	DefaultKeySize = 50
 
@@ -9,7 +10,7 @@ const (
 	// controller中restQueue队列休息时间步长（10分钟）
 	LevelRestStep = uint64(600)
 
-	// 计算频率的比例系数（千分比）
+	// 计算频率的比例系数（千分比），EvictionConfig中的各个阈值都以此为基准
 	ScaleFactor = uint64(1000)
 
 	// 默认管理的对象的个数
@@ -1,10 +1,14 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"objectCache/internal"
 	"objectCache/internal/storage"
-	"time"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // var InitDelete = 0
@@ -37,435 +41,388 @@ import (
 	restQueue：当node的稳定性越高则放入的restQueue的等级越高，node的检查时间间隔越长，最终减少对CPU的占用。
 	destroyQueue：当node在restQueue里面判断稳定性大幅下降且访问频率很低，没有直接淘汰而是存入destroyQueue，也是给次node最后一次机会，当
 		次node在次期间稳定性大幅上升，则再次放入initialQueue，这样避免某些对象qf大幅波动导致被淘汰。
+
+ 分片：
+	controller分为多个分片（shard），每个分片管理连续的一部分分段中的node，有独立的接收channel、访问记录缓冲区、initialQueue、
+	restQueue、destroyQueue和处理协程，分片之间并行处理，写入速率很高时避免所有node都由一个协程处理。淘汰判断使用的整个缓存的对象数量、
+	平均访问频率由各分片的统计汇总（每个分片处理一批到期的node前汇总一次），restQueue休息时间步长的动态调整由0号分片统一进行。
 */
 type Controller struct {
-	// 用于接收用户存储对象时的node，由于sliceChannel是一个不限定容量的channel，这样用户在高并发下也不会由于channel容量占满而被阻塞。
-	unlimitedChannel *internal.UnlimitedChannel
+	maxCount int32 // 用户设置的最大对象数量
+	cfg      internal.EvictionConfig
+
+	segment *storage.Segments
 
-	maxCount      int32 // 用户设置的最大对象数量
-	restNodeCount int32 // 在restQueue队列中的对象数量
+	// 分片，个数为2的幂
+	shards []*shard
+	// 分段下标右移shardShift位即为分片下标
+	shardShift uint
+	// 接收channel积压的node个数达到上限的次数
+	intakeOverflow uint64
 
-	TotalCount uint64 // 总的访问次数
-	TotalTime  uint64 // 总的时长（每一个node的存活时长的总和）
+	// 关闭后所有分片的处理协程退出
+	closed    chan struct{}
+	closeOnce sync.Once
 
-	segment   *[storage.MaxSegmentSize]*storage.Storage
-	nodeCache *internal.NodeCache
+	// 同步淘汰时第一个处理的分片，轮流开始，避免总是淘汰同一个分片中的对象
+	evictNext uint32
 
-	// initialQueue 初始队列，刚存储的对象首先添加到初始队列，初始队列只会淘汰加入后没有被访问的node，
-	// 其他全部加入levelQueue的1级队列（为在1级队列中做做淘汰判断提供初始数据）。
-	initialQueue *restQueue
-	// restQueue 分等级的队列，等级越高则存储node的qf越稳定（波动小），休息时间越长。这样越稳定的数据，进行淘汰判断的频率就越低，减少对系统资源的消耗。
-	restQueue [internal.LevelSize]*restQueue
-	// destroyQueue 删除队列，不会做稳定性判断，如果访问率有增加则添加到levelQueue的1级队列，如果没有增加则确定淘汰对象。
-	destroyQueue *restQueue
+	// restQueue当前的休息时间步长，由adjustEliminateParam()动态调整
+	stepTime uint32
 
-	updateTotalBeginTime int64
+	// 占用名额的对象数量，存入storage前占用，从storage中删除后释放
+	objCount int32
+
+	// 准入策略使用的访问频率估算，为nil则不启用准入策略
+	sketch *internal.FrequencySketch
+	// 最近被淘汰对象的估算访问频率（指数移动平均，放大16倍存储）
+	victimFrequency uint32
 }
 
-func NewController(maxCount int32, segment *[storage.MaxSegmentSize]*storage.Storage,
-	nodeCache *internal.NodeCache) (c *Controller) {
+// evictRequest 同步淘汰请求，淘汰完成后将实际淘汰的数量写入result
+type evictRequest struct {
+	n      int
+	result chan int
+}
+
+// NewController 创建controller，cfg需要先经过internal.EvictionConfig.Validate()检查；
+// shards为分片个数（2的幂，不大于分段个数，超出范围时限定在[1, 分段个数]）；
+// intakeLimit为积压（还没有被分片处理）的node个数上限，平均分配到每个分片，为0则不限制；
+// sketch不为nil则在淘汰时记录被淘汰对象的估算访问频率，供准入策略使用
+func NewController(maxCount int32, cfg internal.EvictionConfig, segment *storage.Segments, shards int,
+	intakeLimit int64, sketch *internal.FrequencySketch) (c *Controller) {
 	c = &Controller{
-		unlimitedChannel:     internal.NewUnlimitedChannel(),
-		maxCount:             maxCount,
-		segment:              segment,
-		nodeCache:            nodeCache,
-		destroyQueue:         newRestQueue(uint32(internal.LevelRestStep)),
-		initialQueue:         newRestQueue(uint32(internal.LevelRestStep)),
-		updateTotalBeginTime: time.Now().Unix(),
+		sketch:   sketch,
+		maxCount: maxCount,
+		cfg:      cfg,
+		segment:  segment,
+		stepTime: cfg.LevelRestStep,
+		closed:   make(chan struct{}),
 	}
 
-	var i uint16
-	for i = 0; i < internal.LevelSize; i++ {
-		c.restQueue[i] = newRestQueue(uint32(internal.LevelRestStep) * (uint32(i) + 1))
+	if shards < 1 {
+		shards = 1
+	} else if shards > len(segment.List) {
+		shards = len(segment.List)
+	}
+	for 1<<c.shardShift < len(segment.List)/shards {
+		c.shardShift++
+	}
+	c.shards = make([]*shard, len(segment.List)>>c.shardShift)
+	// 每个分片的上限向上取整，避免上限小于分片个数时为0（不限制）
+	shardLimit := (intakeLimit + int64(len(c.shards)) - 1) / int64(len(c.shards))
+	for k := range c.shards {
+		c.shards[k] = newShard(c, shardLimit, k == 0)
+	}
+	// 分段中对象的访问记录写入管理此分段的分片的缓冲区
+	for k, seg := range segment.List {
+		seg.SetReadBuffer(c.shards[k>>c.shardShift].reads)
 	}
-
-	go c.handle()
 
 	return c
 }
 
 func (c *Controller) AddNode(n *internal.Node) {
-	c.unlimitedChannel.SetNode(n)
+	c.shardOf(n.Hash).unlimitedChannel.SetNode(n)
 }
 
-func (c *Controller) setTotalCountAndTotalTime(currentCount, currentTime uint32) {
-	//
-	// if (c.TotalTime + uint64(currentTime)) > 0xffffffffffffffff {
-	//	cacheAverageQf := (c.TotalCount * internal.ScaleFactor * internal.NodeUnitRestTime) / c.TotalTime
-	//	fmt.Printf("cache等比例缩放%d(%d-%d) ==>", cacheAverageQf, c.TotalTime, c.TotalCount)
-	//	rate := float64(c.TotalTime) / float64(c.TotalCount)
-	//
-	//	c.TotalCount = uint64(float64(c.TotalCount)/rate) + uint64(currentCount)
-	//	c.TotalTime = uint64(float64(c.TotalTime)/rate) + uint64(currentTime)
-	//	cacheAverageQf = (uint64(c.TotalCount) * internal.ScaleFactor * internal.NodeUnitRestTime) / c.TotalTime
-	//	fmt.Printf("%d(%d-%d) %s \n", cacheAverageQf, c.TotalTime, c.TotalCount,c.GetQueueCount())
-	// } else {
-	//	c.TotalCount += uint64(currentCount)
-	//	c.TotalTime += uint64(currentTime)
-	// }
-
-	// 总的访问次数和总的访问qf次数（大于休息队列的最大休息时间则等比例缩放）
-	now := time.Now().Unix()
-	if now-c.updateTotalBeginTime >= int64(internal.LevelSize*internal.LevelRestStep) {
-		cacheAverageQf := (c.TotalCount * internal.ScaleFactor * internal.NodeUnitRestTime) / c.TotalTime
-		fmt.Printf("cache等比例缩放%d(%d-%d) ==>", cacheAverageQf, c.TotalTime, c.TotalCount)
-
-		c.TotalCount = c.TotalCount/2 + uint64(currentCount)
-		c.TotalTime = c.TotalTime/2 + uint64(currentTime)
-		cacheAverageQf = (c.TotalCount * internal.ScaleFactor * internal.NodeUnitRestTime) / c.TotalTime
-		fmt.Printf("%d(%d-%d) %s \n", cacheAverageQf, c.TotalTime, c.TotalCount, c.GetQueueCount())
-		c.updateTotalBeginTime = now
-	} else {
-		c.TotalCount += uint64(currentCount)
-		c.TotalTime += uint64(currentTime)
-	}
+// shardOf hash对应的分片
+func (c *Controller) shardOf(hash uint64) *shard {
+	return c.shards[c.segment.Index(hash)>>c.shardShift]
 }
 
-// eliminate 进行判断并做淘汰（淘汰算法在此）
-// currentCount：当前访问次数； currentRestUnit：当前node此次睡眠期间的单位时间个数
-func (c *Controller) eliminate(level int, currentCount, currentTime uint64, node *internal.Node) {
-
-	// 当前node在此次睡眠期间的访问频率
-	currentQf := (currentCount * internal.ScaleFactor * internal.NodeUnitRestTime) / currentTime
-
-	// 当前node整个生命周期的访问频率
-	var nodeAverageQf = uint64(0)
-	if node.TotalTime != 0 {
-		nodeAverageQf = uint64(node.TotalCount) * internal.ScaleFactor * internal.NodeUnitRestTime / uint64(node.TotalTime)
+// Congested hash对应的分片积压的node个数是否达到上限，达到上限时记录一次
+func (c *Controller) Congested(hash uint64) (ok bool) {
+	if c.shardOf(hash).unlimitedChannel.Full() {
+		atomic.AddUint64(&c.intakeOverflow, 1)
+		return true
 	}
+	return false
+}
 
-	// 当前整个缓存的访问频率
-	var cacheAverageQf = uint64(0)
-	if c.TotalTime != 0 {
-		cacheAverageQf = (c.TotalCount * internal.ScaleFactor * internal.NodeUnitRestTime) / c.TotalTime
+// WaitIntake 阻塞到hash对应的分片积压的node个数下降到上限的一半以下。ctx结束时返回ctx.Err()，controller关闭时返回internal.ErrClosed
+func (c *Controller) WaitIntake(ctx context.Context, hash uint64) (err error) {
+	s := c.shardOf(hash)
+	if !s.unlimitedChannel.Full() {
+		return nil
 	}
 
-	// 计算当前node的稳定性（node在休息时间内的qf占此node的平均qf的比例）
-	var nodeStability = uint64(0)
-	if nodeAverageQf != 0 {
-		nodeStability = currentQf * internal.ScaleFactor / nodeAverageQf
+	done, stop := mergeDone(ctx, c.closed)
+	defer stop()
+	if s.unlimitedChannel.WaitNotFull(done) {
+		return nil
 	}
-
-	// 计算出淘汰比例
-	eliminateRatio := uint64(c.restNodeCount+c.initialQueue.count+c.destroyQueue.count) * internal.ScaleFactor / uint64(c.maxCount)
-	// eliminateRatio := uint64(c.restNodeCount+c.destroyQueue.count) * internal.ScaleFactor / uint64(c.maxCount)
-
-	if eliminateRatio >= 950 {
-		eliminateRatio = eliminateRatio - 950
-	} else {
-		eliminateRatio = 0
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
+	return internal.ErrClosed
+}
 
-	var nodeEliminateRatio = uint64(0)
-	if cacheAverageQf != 0 {
-		nodeEliminateRatio = (currentQf * internal.ScaleFactor) / (cacheAverageQf * 2)
-	}
+// EvictShard 由hash对应的分片处理积压的node并同步淘汰最多n个对象，返回实际淘汰的数量
+func (c *Controller) EvictShard(ctx context.Context, hash uint64, n int) (count int, err error) {
+	return c.shardOf(hash).evictSync(ctx, n)
+}
 
-	// if PrintFlag % 500000 == 0 {
-	//	fmt.Printf("==> level:%d; 当前频率 %d:(%d*20000 / %d); node频率 %d:(%d*20000 / %d); cache频率 %d:(%d*20000 / %d); " +
-	//		"稳定性:%d 淘汰比例:%d \n", level,currentQf, currentCount,currentTime, nodeAverageQf, node.TotalCount, node.TotalTime,
-	//		cacheAverageQf, c.TotalCount, c.TotalTime, nodeStability, eliminateRatio)
-	//	PrintFlag++
-	// }
-
-	node.UpdateNodeData(uint32(currentTime))
-
-	// nodeStability下降50%，则判断稳定性大幅下降，判断当前node的qf是否达到淘汰比例，达到移入destroyQueue队列。
-	// 则当currentQf为0（即在当前休息时间内没有被访问），则必定移入destroyQueue队列。
-	if nodeStability < 500 && nodeEliminateRatio <= eliminateRatio {
-		// fmt.Printf("%s addNode: restQueue[%d] ==> destroy, key:%d\n", time.Now().Format("15:04:05"), level, node.Hash)
-		c.destroyQueue.addNode(node)
-		c.restNodeCount--
-		// fmt.Printf("结果:destroyQueue \n")
-	} else {
-		// 降级处理
-
-		var levelTemp int
-		if nodeStability >= 900 || nodeStability <= 1100 {
-			// 降级处理：波动在10%则上升1级
-			if level < internal.LevelSize-1 {
-				levelTemp = level + 1
-			} else {
-				levelTemp = level
-			}
-		} else if nodeStability < 800 {
-			// 降级处理：下降20%以上，则降级处理，多降10%则多降一级
-			levelNum := int(800-nodeStability+90) / 100
-			if level-levelNum > 0 {
-				levelTemp = level - levelNum
-			} else {
-				levelTemp = 0
-			}
-		} else {
-			// 降级处理：下降10%到20%或者上升大于10%，则保留原级
-			levelTemp = level
-		}
+// Close 停止所有分片的处理协程，之后同步淘汰、等待积压返回internal.ErrClosed。队列中的node不再处理
+func (c *Controller) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+}
 
-		// 更新cache总数
-		c.setTotalCountAndTotalTime(uint32(currentCount), uint32(currentTime))
-		c.restQueue[levelTemp].addNode(node)
+// Backlog 已经加入、还没有被分片处理的node个数
+func (c *Controller) Backlog() (count int64) {
+	for _, s := range c.shards {
+		count += s.unlimitedChannel.Len()
 	}
-
+	return count
 }
 
 // 动态调整restQueue队列休息的基本时间（第一级队列的休息时间）
 // 达到在不同的使用场景下，对系统的压力趋于稳定：当缓存数量过大时，休息队列的休息时间增大，相同时间内缓存对象被检查的次数减少，反之则相反。
 func (c *Controller) adjustEliminateParam() {
+	if c.cfg.DisableAdaptive {
+		return
+	}
 
-	var totalCount = c.restNodeCount + c.initialQueue.count + c.destroyQueue.count
-	// var totalCount = c.restNodeCount + c.destroyQueue.count
-
-	var countRatio = uint64(totalCount) * internal.ScaleFactor / internal.DefaultObjCount
+	stepTime := c.targetStepTime(c.GetTotalCount())
+	currentStepTime := c.GetStepTime()
 
-	if countRatio > 1200 || (500 < countRatio && countRatio < 800) {
-		stepTime := internal.LevelRestStep * countRatio / internal.ScaleFactor
-		// fmt.Printf("setRestTime: stepTime=%d ", stepTime)
-		for k, _ := range c.restQueue {
-			// fmt.Printf("restQueue[%d]=%d; ",k, stepTime * uint32(k+1))
-			c.restQueue[k].setRestTime(uint32(stepTime) * uint32(k+1))
-		}
-		// fmt.Printf("\n ")
-	} else if countRatio <= 500 {
-		stepTime := internal.LevelRestStep / 2
-		// fmt.Printf("setRestTime: stepTime=%d ", stepTime)
-		for k, _ := range c.restQueue {
-			//	fmt.Printf("restQueue[%d]=%d; ",k, stepTime * (k+1))
-			c.restQueue[k].setRestTime(uint32(stepTime * uint64(k+1)))
-		}
-		// fmt.Printf("\n ")
+	// 调整幅度小于AdaptiveHysteresis则不调整
+	var diff uint32
+	if stepTime > currentStepTime {
+		diff = stepTime - currentStepTime
+	} else {
+		diff = currentStepTime - stepTime
+	}
+	if uint64(diff)*internal.ScaleFactor < *c.cfg.AdaptiveHysteresis*uint64(currentStepTime) {
+		return
 	}
+
+	c.setStepTime(stepTime)
 }
 
-func (c *Controller) handle() {
+// targetStepTime 根据对象数量占最大缓存数量的比例计算休息时间步长，结果限定在[MinRestStep, MaxRestStep]
+func (c *Controller) targetStepTime(totalCount int32) (stepTime uint32) {
+	var countRatio = uint64(totalCount) * internal.ScaleFactor / uint64(c.maxCount)
 
-	var getTicker = time.NewTicker(time.Second)
-	defer getTicker.Stop()
+	step := uint64(c.cfg.LevelRestStep)
+	if countRatio < *c.cfg.AdaptiveLower || countRatio > c.cfg.AdaptiveUpper {
+		step = step * countRatio / internal.ScaleFactor
+	}
 
-	var adjustLevelQueueTicker = time.NewTicker(time.Second * time.Duration(internal.LevelRestStep))
-	defer adjustLevelQueueTicker.Stop()
+	if step < uint64(c.cfg.MinRestStep) {
+		step = uint64(c.cfg.MinRestStep)
+	} else if step > uint64(c.cfg.MaxRestStep) {
+		step = uint64(c.cfg.MaxRestStep)
+	}
 
-	var nodes = make([]*internal.Node, 100)
+	return uint32(step)
+}
 
-	for {
+// setStepTime 设置restQueue的休息时间步长，由各分片在自己的协程中更新restQueue的休息时间
+func (c *Controller) setStepTime(stepTime uint32) {
+	atomic.StoreUint32(&c.stepTime, stepTime)
+	for _, s := range c.shards {
 		select {
-		case t := <-getTicker.C:
-
-			now := uint32(t.Unix())
-
-			// 处理初始队列
-			c.initialQueueHandle(nodes, now)
-			// 处理休息队列
-			c.restQueueHandle(nodes, now)
-			// 处理删除队列
-			c.destroyQueueHandle(nodes, now)
-
-		case <-adjustLevelQueueTicker.C:
-
-			// c.adjustEliminateParam()
-			fmt.Print("\n")
-			fmt.Print(c.GetQueueCount())
-			// fmt.Print("\n")
+		case s.stepChanged <- struct{}{}:
 		default:
-
-			node, ok := c.unlimitedChannel.GetNode()
-			if ok {
-				// fmt.Printf("%s addNode: user ==> init, key:%d\n",time.Now().Format("15:04:05"), node.Hash)
-				node.UpdateNodeData(0)
-				c.initialQueue.addNode(node)
-				// c.restQueue[0].addNode(node)
-
-			} else {
-				time.Sleep(time.Millisecond)
-			}
 		}
 	}
 }
 
-// 处理初始队列
-func (c *Controller) initialQueueHandle(nodes []*internal.Node, now uint32) {
-	// 清空切片
-	nodes = nodes[0:0]
+// GetStepTime 获取当前restQueue的休息时间步长（单位为秒）
+func (c *Controller) GetStepTime() (stepTime uint32) {
+	return atomic.LoadUint32(&c.stepTime)
+}
 
-	var currentCount uint32
+// Admit 准入判断：新增对象的估算访问频率需要大于最近被淘汰对象的估算访问频率。没有启用准入策略则全部准入
+func (c *Controller) Admit(hash uint64) (ok bool) {
+	if c.sketch == nil {
+		return true
+	}
+	return c.sketch.Estimate(hash)<<4 > atomic.LoadUint32(&c.victimFrequency)
+}
 
-	nodes = c.initialQueue.getExpireNodes(now, nodes)
+// NearCapacity 对象数量是否达到开始淘汰的比例（EliminateThreshold）
+func (c *Controller) NearCapacity() (ok bool) {
+	return uint64(c.GetObjCount())*internal.ScaleFactor >= *c.cfg.EliminateThreshold*uint64(c.maxCount)
+}
 
-	for k, _ := range nodes {
+// Reserve 为新增的对象占用一个名额。strict为true时，对象数量达到maxCount则占用失败
+func (c *Controller) Reserve(strict bool) (ok bool) {
+	if !strict {
+		atomic.AddInt32(&c.objCount, 1)
+		return true
+	}
 
-		if c.directEliminate(nodes[k], now) {
-			// fmt.Printf("init\n")
-			continue
+	for {
+		count := atomic.LoadInt32(&c.objCount)
+		if count >= c.maxCount {
+			return false
 		}
-
-		// 在初始队列中没有被访问，则直接淘汰
-		if nodes[k].GetCurrentCount() == 0 {
-			_, ok := c.segment[nodes[k].Hash%storage.MaxSegmentSize].Del(nodes[k].Hash)
-			if ok {
-				c.nodeCache.SaveNode(nodes[k])
-			} else {
-				nodes[k].Hash = 0
-			}
-			// InitDelete++
+		if atomic.CompareAndSwapInt32(&c.objCount, count, count+1) {
+			return true
 		}
-
-		currentCount = nodes[k].GetCurrentCount()
-
-		c.setTotalCountAndTotalTime(currentCount, c.initialQueue.restTime)
-
-		nodes[k].UpdateNodeData(c.initialQueue.restTime)
-
-		// fmt.Printf("%s addNode: init ==> restQueue[0], key:%d\n",time.Now().Format("15:04:05"), nodes[k].Hash)
-		c.restQueue[0].addNode(nodes[k])
-
-		c.restNodeCount++
 	}
 }
 
-// 处理休息队列
-func (c *Controller) restQueueHandle(nodes []*internal.Node, now uint32) {
-
-	var currentCount, currentTime uint32
-
-	// 处理休息队列
-	for k, _ := range c.restQueue {
-
-		// 清空切片
-		nodes = nodes[0:0]
-
-		nodes = c.restQueue[k].getExpireNodes(now, nodes)
-
-		for kk, _ := range nodes {
-			if c.directEliminate(nodes[kk], now) {
-				// fmt.Printf("%d\n", k)
-				c.restNodeCount--
-				continue
-			}
-
-			currentCount = nodes[kk].GetCurrentCount()
+// Release 释放一个名额，对象没有存入或者从storage中删除后调用
+func (c *Controller) Release() {
+	atomic.AddInt32(&c.objCount, -1)
+}
 
-			currentTime = now - nodes[kk].RestBeginTime
+// GetObjCount 获取占用名额的对象数量
+func (c *Controller) GetObjCount() (count int32) {
+	return atomic.LoadInt32(&c.objCount)
+}
 
-			// 对当前的node进行淘汰ls
-			c.eliminate(k, uint64(currentCount), uint64(currentTime), nodes[kk])
+// Evict 同步淘汰最多n个对象，返回实际淘汰的数量。由各分片的handle()协程依次执行，调用者会阻塞到淘汰完成、ctx结束或者controller关闭
+func (c *Controller) Evict(ctx context.Context, n int) (count int, err error) {
+	start := int(atomic.AddUint32(&c.evictNext, 1))
+	for k := 0; k < len(c.shards) && count < n; k++ {
+		evicted, err := c.shards[(start+k)&(len(c.shards)-1)].evictSync(ctx, n-count)
+		count += evicted
+		if err != nil {
+			return count, err
 		}
 	}
+	return count, nil
 }
 
-// 处理删除队列
-func (c *Controller) destroyQueueHandle(nodes []*internal.Node, now uint32) {
-
-	// 清空切片
-	nodes = nodes[0:0]
-
-	nodes = c.destroyQueue.getExpireNodes(now, nodes)
-
-	var deleteCount = c.maxCount - c.restNodeCount - c.initialQueue.count - c.destroyQueue.count
-	// var deleteCount = c.maxCount - c.restNodeCount - c.destroyQueue.count
-	for k, _ := range nodes {
+// GetTotalCount 所有分片的队列中node的总数
+func (c *Controller) GetTotalCount() (count int32) {
+	for _, s := range c.shards {
+		count += s.totalNodeCount()
+	}
+	return count
+}
 
-		if c.directEliminate(nodes[k], now) {
-			// fmt.Printf("destroy\n")
-			continue
-		}
+// totals 所有分片总的访问次数和总的时长
+func (c *Controller) totals() (count, seconds uint64) {
+	for _, s := range c.shards {
+		count += atomic.LoadUint64(&s.TotalCount)
+		seconds += atomic.LoadUint64(&s.TotalTime)
+	}
+	return count, seconds
+}
 
-		currentCount := uint64(nodes[k].GetCurrentCount())
+func (c *Controller) GetQueueCount() (result string) {
 
-		// 当前node的访问频率
-		averageQf := uint64(nodes[k].TotalCount) * internal.ScaleFactor * internal.NodeUnitRestTime / uint64(nodes[k].TotalTime)
+	stats := c.GetStats()
+	totalCount, totalTime := c.totals()
 
-		// 当前node在此次睡眠期间的访问频率
-		currentQf := currentCount * internal.ScaleFactor * internal.NodeUnitRestTime / uint64(c.destroyQueue.restTime)
+	var b strings.Builder
+	b.WriteString("node count: ")
+	b.WriteString(strconv.Itoa(int(stats.InitialCount)))
+	for k := range stats.RestCount {
+		b.WriteString("-")
+		b.WriteString(strconv.Itoa(int(stats.RestCount[k])))
+	}
+	b.WriteString("-")
+	b.WriteString(strconv.Itoa(int(stats.DestroyCount)))
 
-		// node 的稳定性
-		var nodeStability = uint64(0)
-		if averageQf != 0 {
-			nodeStability = currentQf * internal.ScaleFactor / averageQf
-		}
+	result = fmt.Sprintf("%s 总数量:%d 淘汰率：%d 平均访问频率:%d(%d * 20000 - %d) 休息步长:%ds", b.String(),
+		stats.TotalCount, stats.EliminateRatio, stats.AverageQf,
+		totalCount, totalTime, stats.RestStepTime)
 
-		// 对于淘汰队列中到期，需要删除的node进行捡漏：
-		// 1、在destroyQueue队列中休息期间的访问率达到此node的平均访问率；
-		// 2、在destroyQueue队列中休息期间的访问率达到此node的平均访问率的70%，并且整个系统没有待淘汰的数量
-		if nodeStability >= 1000 || (deleteCount <= 0 && nodeStability >= 700) {
-
-			// fmt.Printf("%s addNode: destroy ==> restQueue[0], key:%d\n",time.Now().Format("15:04:05"), nodes[k].Hash)
-			c.setTotalCountAndTotalTime(uint32(currentCount), c.destroyQueue.restTime)
-			nodes[k].UpdateNodeData(c.destroyQueue.restTime)
-			c.restQueue[0].addNode(nodes[k])
-			c.restNodeCount++
-		} else {
-
-			// test
-			// DeleteCount++
-			// DeleteNodeMap.Store(nodes[k].Hash, nodes[k])
-
-			// fmt.Println("delete the node: ", nodes[k].Hash)
-			_, ok := c.segment[nodes[k].Hash%storage.MaxSegmentSize].Del(nodes[k].Hash)
-			if ok {
-				c.nodeCache.SaveNode(nodes[k])
-			} else {
-				nodes[k].Hash = 0
-			}
-
-			deleteCount--
-		}
-	}
+	return result
 }
 
-// directEliminate 进行直接淘汰：1、被外部删除；2、对象过期。
-func (c *Controller) directEliminate(node *internal.Node, now uint32) (ok bool) {
-	// 被用户主动删除，直接丢弃
-	if node.Obj == nil {
-		// 此处清除hash，作为recoverNode()进行判断的依据
-		node.Hash = 0
-
-		// fmt.Printf("directEliminate==> 用户删除 key: %d-", node.Hash)
+// Stats controller的统计信息（瞬时值）
+type Stats struct {
+	// 各个队列中的对象数量
+	InitialCount int32
+	RestCount    []int32
+	DestroyCount int32
+	// 管理的对象总数
+	TotalCount int32
+
+	// 当前的淘汰比例（千分比）
+	EliminateRatio uint64
+	// 整个缓存的平均访问频率（千分比）
+	AverageQf uint64
+	// restQueue当前的休息时间步长（单位为秒）
+	RestStepTime uint32
+	// 最近被淘汰对象的估算访问频率（[0, 15]，启用准入策略时有效）
+	VictimFrequency uint32
+
+	// 已经加入、还没有被处理的对象数量
+	Backlog int64
+	// 积压的对象数量达到上限（Options.IntakeLimit）的次数
+	IntakeOverflow uint64
+}
 
-		return true
+// GetStats 获取controller的统计信息
+func (c *Controller) GetStats() (stats Stats) {
+	stats = Stats{
+		RestCount:    make([]int32, c.cfg.LevelSize),
+		RestStepTime: c.GetStepTime(),
+		// 四舍五入
+		VictimFrequency: (atomic.LoadUint32(&c.victimFrequency) + 8) >> 4,
+		Backlog:         c.Backlog(),
+		IntakeOverflow:  atomic.LoadUint64(&c.intakeOverflow),
 	}
-	// 过期，直接调用接口删除
-	if now >= node.Expire {
-		_, ok := c.segment[node.Hash%storage.MaxSegmentSize].Del(node.Hash)
-		if ok {
-			c.nodeCache.SaveNode(node)
-		} else {
-			node.Hash = 0
+	for _, s := range c.shards {
+		stats.InitialCount += s.initialQueue.len()
+		stats.DestroyCount += s.destroyQueue.len()
+		for k := range s.restQueue {
+			stats.RestCount[k] += s.restQueue[k].len()
 		}
-		// fmt.Printf("directEliminate==> 过期    key: %d-", node.Hash)
-		return true
 	}
-
-	return false
+	stats.TotalCount = c.GetTotalCount()
+	stats.EliminateRatio = c.eliminateRatio(stats.TotalCount)
+	stats.AverageQf = c.averageQf()
+	return stats
 }
 
-func (c *Controller) GetTotalCount() (count int32) {
-
-	return c.restNodeCount + c.initialQueue.count + c.destroyQueue.count
+// qf 计算访问频率（千分比），count为访问次数，seconds为时长
+func (c *Controller) qf(count, seconds uint64) uint64 {
+	if seconds == 0 {
+		return 0
+	}
+	return count * internal.ScaleFactor * uint64(c.cfg.NodeUnitRestTime) / seconds
 }
 
-func (c *Controller) GetQueueCount() (result string) {
+// Qf 根据node的总访问次数、总时长（internal.NodeMeta）计算访问频率，与淘汰判断、Stats.AverageQf的计算方式相同
+func (c *Controller) Qf(totalCount, totalTime uint32) uint64 {
+	return c.qf(uint64(totalCount), uint64(totalTime))
+}
 
-	eliminateRatio := uint64(c.restNodeCount+c.initialQueue.count+c.destroyQueue.count) * internal.ScaleFactor / uint64(c.maxCount)
+// averageQf 整个缓存的平均访问频率
+func (c *Controller) averageQf() uint64 {
+	return c.qf(c.totals())
+}
 
-	if eliminateRatio >= 950 {
-		eliminateRatio = eliminateRatio - 950
-	} else {
-		eliminateRatio = 0
+// eliminateRatio 计算淘汰比例：对象数量（totalCount）占最大数量的比例超过EliminateThreshold的部分
+func (c *Controller) eliminateRatio(totalCount int32) uint64 {
+	ratio := uint64(totalCount) * internal.ScaleFactor / uint64(c.maxCount)
+	if ratio >= *c.cfg.EliminateThreshold {
+		return ratio - *c.cfg.EliminateThreshold
 	}
-	var cacheAverageQf uint64
-	if c.TotalCount != 0 {
-		cacheAverageQf = (c.TotalCount * internal.ScaleFactor * internal.NodeUnitRestTime) / c.TotalTime
-	}
-	result = fmt.Sprintf("node count: %d-%d-%d-%d-%d-%d-%d-%d-%d-%d-%d-%d 总数量:%d 淘汰率：%d "+
-		"平均访问频率:%d(%d * 20000 - %d)", c.initialQueue.count, c.restQueue[0].count,
-		c.restQueue[1].count, c.restQueue[2].count, c.restQueue[3].count, c.restQueue[4].count, c.restQueue[5].count,
-		c.restQueue[6].count, c.restQueue[7].count, c.restQueue[8].count, c.restQueue[9].count, c.destroyQueue.count,
-		c.initialQueue.count+c.restNodeCount+c.destroyQueue.count, eliminateRatio, cacheAverageQf,
-		c.TotalCount, c.TotalTime)
-
-	return result
+	return 0
 }
 
 // func (c *Controller) GetDeleteNode() (m sync.Map) {
 //
 //	return DeleteNodeMap
 // }
+
+// mergeDone 返回ctx结束或者closed被关闭时关闭的channel，不再使用时调用stop。ctx不会结束时直接返回closed
+func mergeDone(ctx context.Context, closed chan struct{}) (done <-chan struct{}, stop func()) {
+	if ctx.Done() == nil {
+		return closed, func() {}
+	}
+
+	merged := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-closed:
+		case <-stopped:
+		}
+		close(merged)
+	}()
+	return merged, func() { close(stopped) }
+}
@@ -1,9 +1,13 @@
 package controller
 
 import (
+	"context"
+	"fmt"
 	_ "net/http/pprof"
 	"objectCache/internal"
 	"objectCache/internal/storage"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -20,27 +24,362 @@ func TestController_1(t *testing.T) {
 	//	http.ListenAndServe("localhost:13001", nil)
 	// }()
 
-	var segments [storage.MaxSegmentSize]*storage.Storage
-	for i := 0; i < storage.MaxSegmentSize; i++ {
-		segments[i] = &storage.Storage{NodeMap: make(map[uint64]*internal.Node)}
-	}
-	var nodeCache = internal.NewNodeCache(1e6 / 4)
-	c = NewController(1e6, &segments, nodeCache)
+	segments := storage.NewSegments(storage.DefaultSegmentCount(), internal.NodeUnitRestTime)
+	c = NewController(1e6, internal.DefaultEvictionConfig(), segments, 1, 0, nil)
 
-	node := c.nodeCache.GetNode()
 	var hash = uint64(1)
-	ok := c.segment[hash%storage.MaxSegmentSize].Set(objData{id: 1, name: "1"}, hash, 0, node)
+	node, ok := c.segment.Of(hash).Set(objData{id: 1, name: "1"}, hash, 0, 0)
 	if ok {
 		c.AddNode(node)
 	}
 
-	time.Sleep(time.Microsecond * 10)
+	// 分片处理同步淘汰前先读取新增的node
+	_, _ = c.EvictShard(context.Background(), hash, 0)
 
-	if c.initialQueue.count != 1 {
-		t.Error("失败1", c.initialQueue.count)
+	if c.shards[0].initialQueue.len() != 1 {
+		t.Error("失败1", c.shards[0].initialQueue.len())
 	}
 
 	// select {
 	//
 	// }
 }
+
+func TestController_AdjustEliminateParam(t *testing.T) {
+	segments := storage.NewSegments(storage.DefaultSegmentCount(), internal.NodeUnitRestTime)
+	cfg := internal.EvictionConfig{LevelRestStep: 60}.WithDefaults()
+	ct := NewController(1e4, cfg, segments, 1, 0, nil)
+
+	// 对象数量在[80%, 120%]之间使用默认步长，超出则按比例调整，并限定在[MinRestStep, MaxRestStep]
+	if ct.targetStepTime(1e4) != 60 {
+		t.Error("失败1")
+	}
+	if ct.targetStepTime(1.5e4) != 90 {
+		t.Error("失败2")
+	}
+	if ct.targetStepTime(1e6) != cfg.MaxRestStep {
+		t.Error("失败3")
+	}
+	if ct.targetStepTime(0) != cfg.MinRestStep {
+		t.Error("失败4")
+	}
+
+	atomic.StoreInt32(&ct.shards[0].restNodeCount, 1.5e4)
+	ct.adjustEliminateParam()
+	// 分片在自己的协程中更新休息时间，同步淘汰（由分片的协程处理）返回时已经更新
+	_, _ = ct.EvictShard(context.Background(), 0, 0)
+	if ct.GetStepTime() != 90 || ct.shards[0].restQueue[1].getRestTime() != 180 {
+		t.Error("失败5", ct.GetStepTime())
+	}
+	if ct.GetStats().RestStepTime != 90 {
+		t.Error("失败6")
+	}
+
+	// 调整幅度小于10%则不调整
+	atomic.StoreInt32(&ct.shards[0].restNodeCount, 1.6e4)
+	ct.adjustEliminateParam()
+	if ct.GetStepTime() != 90 {
+		t.Error("失败7", ct.GetStepTime())
+	}
+}
+
+func TestController_Evict(t *testing.T) {
+	segments := storage.NewSegments(storage.DefaultSegmentCount(), internal.NodeUnitRestTime)
+	ct := NewController(10, internal.DefaultEvictionConfig(), segments, 1, 0, nil)
+
+	for i := 1; i <= 10; i++ {
+		if !ct.Reserve(true) {
+			t.Error("失败1", i)
+		}
+		hash := uint64(i)
+		node, _ := segments.Of(hash).Set(objData{id: i}, hash, 0, 0)
+		ct.AddNode(node)
+	}
+
+	// 达到最大数量后占用失败
+	if ct.Reserve(true) {
+		t.Error("失败2")
+	}
+
+	// 先放入的node先被淘汰
+	if evicted(ct.Evict(context.Background(), 3)) != 3 {
+		t.Error("失败3")
+	}
+	if ct.GetObjCount() != 7 {
+		t.Error("失败4", ct.GetObjCount())
+	}
+	if segments.Of(1).Has(1) || !segments.Of(4).Has(4) {
+		t.Error("失败5")
+	}
+	if !ct.Reserve(true) {
+		t.Error("失败6")
+	}
+}
+
+func TestController_Admit(t *testing.T) {
+	segments := storage.NewSegments(storage.DefaultSegmentCount(), internal.NodeUnitRestTime)
+	sketch := internal.NewFrequencySketch(100)
+	ct := NewController(100, internal.DefaultEvictionConfig(), segments, 1, 0, sketch)
+
+	// 没有淘汰过对象，全部准入
+	sketch.Increment(1000)
+	if !ct.Admit(1000) {
+		t.Error("失败1")
+	}
+
+	// 被淘汰的对象都只访问过2次
+	for i := 1; i <= 50; i++ {
+		hash := uint64(i)
+		sketch.Increment(hash)
+		sketch.Increment(hash)
+		ct.Reserve(false)
+		node, _ := segments.Of(hash).Set(objData{id: i}, hash, 0, 0)
+		ct.AddNode(node)
+	}
+	if evicted(ct.Evict(context.Background(), 50)) != 50 {
+		t.Error("失败2")
+	}
+	if ct.GetStats().VictimFrequency != 2 {
+		t.Error("失败3", ct.GetStats().VictimFrequency)
+	}
+
+	// 只访问过1次的对象被拒绝，访问过3次的对象准入
+	if ct.Admit(1000) {
+		t.Error("失败4")
+	}
+	sketch.Increment(1000)
+	sketch.Increment(1000)
+	if !ct.Admit(1000) {
+		t.Error("失败5")
+	}
+}
+
+func TestController_EvictPinned(t *testing.T) {
+	segments := storage.NewSegments(storage.DefaultSegmentCount(), internal.NodeUnitRestTime)
+	ct := NewController(10, internal.DefaultEvictionConfig(), segments, 1, 0, nil)
+
+	for i := 1; i <= 5; i++ {
+		hash := uint64(i)
+		ct.Reserve(true)
+		node, _ := segments.Of(hash).Set(objData{id: i}, hash, 0, 0)
+		ct.AddNode(node)
+	}
+	segments.Of(1).Pin(1, true)
+	segments.Of(2).Pin(2, true)
+	before := internal.Now()
+	time.Sleep(time.Millisecond * 1100)
+
+	// 固定的node不会被淘汰
+	if evicted(ct.Evict(context.Background(), 5)) != 3 {
+		t.Error("失败1")
+	}
+	// 放回队列尾部时重新开始休息，队列仍按RestBeginTime排序
+	if node, _ := segments.Of(1).Get(1); atomic.LoadUint32(&node.RestBeginTime) <= before {
+		t.Error("失败1.1", node.RestBeginTime, before)
+	}
+	if !segments.Of(1).Has(1) || !segments.Of(2).Has(2) || segments.Of(3).Has(3) {
+		t.Error("失败2")
+	}
+	if ct.shards[0].initialQueue.len() != 2 {
+		t.Error("失败3", ct.shards[0].initialQueue.len())
+	}
+
+	segments.Of(1).Pin(1, false)
+	if evicted(ct.Evict(context.Background(), 5)) != 1 || segments.Of(1).Has(1) {
+		t.Error("失败4")
+	}
+}
+
+func TestController_RestTimer(t *testing.T) {
+	segments := storage.NewSegments(storage.DefaultSegmentCount(), 1)
+	cfg := internal.EvictionConfig{LevelRestStep: 2, NodeUnitRestTime: 1}.WithDefaults()
+	ct := NewController(10, cfg, segments, 1, 0, nil)
+
+	if _, ok := ct.shards[0].nextDeadline(); ok {
+		t.Error("失败1")
+	}
+
+	// 新增的node立即放入initialQueue，不需要等待轮询
+	ct.Reserve(true)
+	node, _ := segments.Of(1).Set(objData{id: 1}, 1, 0, 0)
+	ct.AddNode(node)
+	_, _ = ct.EvictShard(context.Background(), 1, 0)
+	if ct.shards[0].initialQueue.len() != 1 {
+		t.Fatal("失败2")
+	}
+
+	// initialQueue中没有被访问的node到期后由定时器唤醒淘汰
+	time.Sleep(time.Second * 4)
+	if segments.Of(1).Has(1) || ct.GetObjCount() != 0 {
+		t.Error("失败3")
+	}
+}
+
+// fakeClock 测试使用的时间来源
+type fakeClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+// advance 推迟时间并立即更新粗粒度时钟
+func (c *fakeClock) advance(d time.Duration) {
+	c.Lock()
+	c.now = c.now.Add(d)
+	c.Unlock()
+	internal.RefreshClock()
+}
+
+func TestController_FakeClock(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1e6, 0)}
+	internal.SetClock(clock)
+	defer internal.SetClock(nil)
+
+	segments := storage.NewSegments(storage.DefaultSegmentCount(), 1)
+	cfg := internal.EvictionConfig{LevelRestStep: 2, NodeUnitRestTime: 1}.WithDefaults()
+	ct := NewController(10, cfg, segments, 1, 0, nil)
+
+	ct.Reserve(true)
+	node, _ := segments.Of(1).Set(objData{id: 1}, 1, 0, 0)
+	ct.AddNode(node)
+	_, _ = ct.EvictShard(context.Background(), 1, 0)
+
+	// 注入的时间没有变化，系统时间经过多久都不会到期
+	time.Sleep(time.Millisecond * 1100)
+	if !segments.Of(1).Has(1) {
+		t.Fatal("失败1")
+	}
+
+	// 注入的时间到达initialQueue的休息时间后，没有被访问的node被淘汰
+	clock.advance(time.Second * 2)
+	for i := 0; i < 100 && segments.Of(1).Has(1); i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if segments.Of(1).Has(1) || ct.GetObjCount() != 0 {
+		t.Error("失败2")
+	}
+}
+
+func TestController_Shards(t *testing.T) {
+	segments := storage.NewSegments(16, internal.NodeUnitRestTime)
+	ct := NewController(1e4, internal.DefaultEvictionConfig(), segments, 4, 0, nil)
+
+	if len(ct.shards) != 4 || ct.shardShift != 2 {
+		t.Fatal("失败1", len(ct.shards), ct.shardShift)
+	}
+
+	// 每个分段的node都由对应的分片管理
+	for i := uint64(0); i < 16; i++ {
+		hash := i << 60
+		node, _ := segments.Of(hash).Set(objData{id: int(i)}, hash, 0, 0)
+		ct.AddNode(node)
+	}
+	// 分片处理同步淘汰前先读取新增的node
+	for _, s := range ct.shards {
+		_, _ = s.evictSync(context.Background(), 0)
+	}
+
+	for k, s := range ct.shards {
+		if s.initialQueue.len() != 4 {
+			t.Error("失败2", k, s.initialQueue.len())
+		}
+	}
+	if ct.GetTotalCount() != 16 || ct.Backlog() != 0 {
+		t.Error("失败3", ct.GetTotalCount(), ct.Backlog())
+	}
+
+	// 分片个数超出分段个数则限定为分段个数
+	if ct = NewController(1e4, internal.DefaultEvictionConfig(), segments, 64, 0, nil); len(ct.shards) != 16 {
+		t.Error("失败4", len(ct.shards))
+	}
+}
+
+// BenchmarkController_Backlog 持续写入时controller积压（还没有放入initialQueue）的node个数
+func BenchmarkController_Backlog(b *testing.B) {
+	for _, shards := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("shards-%d", shards), func(b *testing.B) {
+			segments := storage.NewSegments(storage.DefaultSegmentCount(), internal.NodeUnitRestTime)
+			ct := NewController(1e8, internal.DefaultEvictionConfig(), segments, shards, 0, nil)
+
+			var seq uint64
+			var maxBacklog int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					hash := atomic.AddUint64(&seq, 1) * 0x9E3779B97F4A7C15
+					node, ok := segments.Of(hash).Set(objData{}, hash, 0, 0)
+					if ok {
+						ct.AddNode(node)
+					}
+					if hash&0x3ff == 0 {
+						if backlog := ct.Backlog(); backlog > atomic.LoadInt64(&maxBacklog) {
+							atomic.StoreInt64(&maxBacklog, backlog)
+						}
+					}
+				}
+			})
+			b.StopTimer()
+
+			b.ReportMetric(float64(atomic.LoadInt64(&maxBacklog)), "max-backlog")
+		})
+	}
+}
+
+func TestController_ReadBuffer(t *testing.T) {
+	segments := storage.NewSegments(16, internal.NodeUnitRestTime)
+	ct := NewController(1e4, internal.DefaultEvictionConfig(), segments, 4, 0, nil)
+
+	hash := uint64(3) << 60
+	node, _ := segments.Of(hash).Set(objData{id: 1}, hash, 0, 0)
+	ct.AddNode(node)
+
+	// 访问记录写入分片的缓冲区，由分片读取后更新访问次数
+	segments.Of(hash).Get(hash)
+	if node.GetCurrentCount() != 0 || !ct.shardOf(hash).reads.Pending() {
+		t.Fatal("失败1")
+	}
+
+	if evicted(ct.EvictShard(context.Background(), hash, 0)) != 0 || node.GetCurrentCount() != 1 {
+		t.Error("失败2", node.GetCurrentCount())
+	}
+	// 放入initialQueue后记录所在的队列
+	if node.GetQueue() != internal.QueueInitial {
+		t.Error("失败3", node.GetQueue())
+	}
+}
+
+// evicted 忽略同步淘汰的错误，只返回淘汰的数量
+func evicted(count int, err error) int {
+	return count
+}
+
+func TestController_Close(t *testing.T) {
+	segments := storage.NewSegments(16, internal.NodeUnitRestTime)
+	ct := NewController(1e4, internal.DefaultEvictionConfig(), segments, 4, 2, nil)
+
+	// ctx结束后不再等待
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ct.Evict(ctx, 1); err != context.Canceled {
+		t.Error("失败1", err)
+	}
+
+	ct.Close()
+	ct.Close()
+	if _, err := ct.Evict(context.Background(), 1); err != internal.ErrClosed {
+		t.Error("失败2", err)
+	}
+
+	// 分片已经停止，积压达到上限后等待返回internal.ErrClosed
+	hash := uint64(1) << 60
+	node, _ := segments.Of(hash).Set(objData{id: 1}, hash, 0, 0)
+	ct.AddNode(node)
+	if err := ct.WaitIntake(context.Background(), hash); err != internal.ErrClosed {
+		t.Error("失败3", err)
+	}
+}
@@ -3,19 +3,22 @@ package controller
 import (
 	"container/list"
 	"objectCache/internal"
+	"sync/atomic"
 )
 
 // 休息队列，由多个queue构成一个可伸缩队列
 type restQueue struct {
-	restTime  uint32 // 休息时长，单位为秒
-	count     int32
+	restTime  uint32 // 休息时长，单位为秒，由分片的协程修改，其他协程通过getRestTime()读取
+	count     int32  // node个数，其他协程通过len()读取
+	place     uint8  // 队列的标识（internal.QueueInitial等），放入的node记录所在的队列
 	queueList *list.List
 }
 
-func newRestQueue(restTime uint32) (q *restQueue) {
+func newRestQueue(restTime uint32, place uint8) (q *restQueue) {
 
 	q = &restQueue{
 		restTime:  restTime,
+		place:     place,
 		queueList: list.New(),
 	}
 
@@ -24,14 +27,24 @@ func newRestQueue(restTime uint32) (q *restQueue) {
 	return q
 }
 
+// len 队列中node的个数
+func (s *restQueue) len() int32 {
+	return atomic.LoadInt32(&s.count)
+}
+
 func (s *restQueue) setRestTime(t uint32) {
-	s.restTime = t
+	atomic.StoreUint32(&s.restTime, t)
+}
+
+// getRestTime 休息时长
+func (s *restQueue) getRestTime() uint32 {
+	return atomic.LoadUint32(&s.restTime)
 }
 
 // 获取到期的所有到期的node
 func (s *restQueue) getExpireNodes(now uint32, n []*internal.Node) (nodes []*internal.Node) {
 
-	expireTime := now - s.restTime
+	expireTime := now - s.getRestTime()
 	var isEnd bool
 	var q *queue
 	for i := s.queueList.Front(); i != nil; i = i.Next() {
@@ -51,18 +64,58 @@ func (s *restQueue) getExpireNodes(now uint32, n []*internal.Node) (nodes []*int
 		}
 
 	}
-	s.count = s.count - int32(len(n))
+	atomic.AddInt32(&s.count, -int32(len(n)))
 	return n
 }
 
+// nextDeadline 队列头部的node到期的时间（单位为秒），队列为空返回false
+func (s *restQueue) nextDeadline() (deadline uint32, ok bool) {
+	for i := s.queueList.Front(); i != nil; i = i.Next() {
+		q := i.Value.(*queue)
+		if q.head < q.tail {
+			return q.queue[q.head].RestBeginTime + s.getRestTime(), true
+		}
+	}
+	return 0, false
+}
+
+// popNode 从头部取出一个node（不论是否到期），用于同步淘汰
+func (s *restQueue) popNode() (n *internal.Node, ok bool) {
+
+	for i := s.queueList.Front(); i != nil; i = s.queueList.Front() {
+		q := i.Value.(*queue)
+		if q.head < q.tail {
+			n = q.queue[q.head]
+			q.queue[q.head] = nil
+			q.head++
+			atomic.AddInt32(&s.count, -1)
+			return n, true
+		}
+
+		// 当前queue已经读取到末尾
+		if q.head == queueNodeSize {
+			q.reset()
+			if s.queueList.Len() > 1 {
+				s.queueList.Remove(i)
+				queueCacheObj.setQueue(q)
+				continue
+			}
+		}
+		break
+	}
+
+	return nil, false
+}
+
 // addNode 添加一个node到末尾
 func (s *restQueue) addNode(n *internal.Node) {
 
+	n.SetQueue(s.place)
 	if !s.queueList.Back().Value.(*queue).pushBack(n) {
 		s.queueList.PushBack(queueCacheObj.getQueue())
 		s.addNode(n)
 	} else {
-		s.count++
+		atomic.AddInt32(&s.count, 1)
 	}
 
 }
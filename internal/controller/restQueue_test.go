@@ -8,9 +8,9 @@ import (
 
 func Test_restQueue_Total(t *testing.T) {
 
-	rq := newRestQueue(10)
+	rq := newRestQueue(10, internal.QueueRest)
 	node := &internal.Node{Hash: 1}
-	node.UpdateNodeData(0)
+	node.UpdateNodeData(0, uint32(internal.LevelRestStep*internal.LevelSize))
 	rq.addNode(node)
 
 	nodes := make([]*internal.Node, 0, 10)
@@ -33,7 +33,7 @@ func Test_restQueue_Total(t *testing.T) {
 
 func Test_restQueue_Total1(t *testing.T) {
 
-	rq := newRestQueue(10)
+	rq := newRestQueue(10, internal.QueueRest)
 
 	nodes := make([]*internal.Node, 0, 10)
 
@@ -44,7 +44,7 @@ func Test_restQueue_Total1(t *testing.T) {
 
 	for i := 0; i < 10000; i++ {
 		node := &internal.Node{Hash: uint64(i)}
-		node.UpdateNodeData(0)
+		node.UpdateNodeData(0, uint32(internal.LevelRestStep*internal.LevelSize))
 		rq.addNode(node)
 	}
 
@@ -62,3 +62,27 @@ func Test_restQueue_Total1(t *testing.T) {
 	}
 
 }
+
+func Test_restQueue_popNode(t *testing.T) {
+
+	rq := newRestQueue(10, internal.QueueRest)
+	for i := 0; i < queueNodeSize+10; i++ {
+		node := &internal.Node{Hash: uint64(i)}
+		rq.addNode(node)
+	}
+
+	for i := 0; i < queueNodeSize+10; i++ {
+		node, ok := rq.popNode()
+		if !ok || node.Hash != uint64(i) {
+			t.Error("失败1", i)
+			break
+		}
+	}
+
+	if _, ok := rq.popNode(); ok {
+		t.Error("失败2")
+	}
+	if rq.count != 0 || rq.queueList.Len() != 1 {
+		t.Error("失败3")
+	}
+}
@@ -0,0 +1,596 @@
+package controller
+
+import (
+	"context"
+	"objectCache/internal"
+	"objectCache/internal/storage"
+	"sync/atomic"
+	"time"
+)
+
+// shard controller的分片，管理连续的一部分分段中的node，详见Controller。
+// 除统计字段（使用原子操作，供其他分片汇总）外，所有字段只在分片的handle()协程中访问
+type shard struct {
+	TotalCount uint64 // 总的访问次数
+	TotalTime  uint64 // 总的时长（每一个node的存活时长的总和）
+
+	restNodeCount int32 // 在restQueue队列中的对象数量
+
+	*Controller
+
+	// 用于接收用户存储对象时的node，由于sliceChannel是一个不限定容量的channel，这样用户在高并发下也不会由于channel容量占满而被阻塞。
+	// 设置了积压上限时，达到上限后由用户按Options.IntakePolicy处理
+	unlimitedChannel *internal.UnlimitedChannel
+
+	// 记录分片管理的分段中对象被访问的缓冲区，由handle()协程批量读取后更新node的访问次数
+	reads *internal.ReadBuffer
+
+	// initialQueue 初始队列，刚存储的对象首先添加到初始队列，初始队列只会淘汰加入后没有被访问的node，
+	// 其他全部加入levelQueue的1级队列（为在1级队列中做做淘汰判断提供初始数据）。
+	initialQueue *restQueue
+	// restQueue 分等级的队列，等级越高则存储node的qf越稳定（波动小），休息时间越长。这样越稳定的数据，进行淘汰判断的频率就越低，减少对系统资源的消耗。
+	restQueue []*restQueue
+	// destroyQueue 删除队列，不会做稳定性判断，如果访问率有增加则添加到levelQueue的1级队列，如果没有增加则确定淘汰对象。
+	destroyQueue *restQueue
+
+	updateTotalBeginTime int64
+
+	// 是否负责动态调整restQueue的休息时间步长（0号分片）
+	adjust bool
+	// 休息时间步长变化的通知
+	stepChanged chan struct{}
+	// 同步淘汰的请求
+	evictChan chan evictRequest
+
+	// 处理一批到期的node前汇总的整个缓存的平均访问频率、淘汰比例
+	cacheAverageQf uint64
+	cacheEliminate uint64
+}
+
+func newShard(c *Controller, intakeLimit int64, adjust bool) (s *shard) {
+	s = &shard{
+		Controller:           c,
+		unlimitedChannel:     internal.NewBoundedChannel(intakeLimit),
+		reads:                internal.NewReadBuffer(),
+		destroyQueue:         newRestQueue(c.cfg.LevelRestStep, internal.QueueDestroy),
+		initialQueue:         newRestQueue(c.cfg.LevelRestStep, internal.QueueInitial),
+		restQueue:            make([]*restQueue, c.cfg.LevelSize),
+		updateTotalBeginTime: int64(internal.Now()),
+		adjust:               adjust,
+		stepChanged:          make(chan struct{}, 1),
+		evictChan:            make(chan evictRequest),
+	}
+
+	for i := 0; i < c.cfg.LevelSize; i++ {
+		s.restQueue[i] = newRestQueue(c.cfg.LevelRestStep*(uint32(i)+1), internal.QueueRest+uint8(i))
+	}
+
+	go s.handle()
+
+	return s
+}
+
+// totalNodeCount 分片的队列中node的总数
+func (s *shard) totalNodeCount() (count int32) {
+	return atomic.LoadInt32(&s.restNodeCount) + s.initialQueue.len() + s.destroyQueue.len()
+}
+
+// evictSync 由handle()协程同步淘汰最多n个对象。ctx结束时返回ctx.Err()（已经发出的请求仍会执行），controller关闭时返回internal.ErrClosed
+func (s *shard) evictSync(ctx context.Context, n int) (count int, err error) {
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	req := evictRequest{n: n, result: make(chan int, 1)}
+	select {
+	case s.evictChan <- req:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-s.closed:
+		return 0, internal.ErrClosed
+	}
+
+	select {
+	case count = <-req.result:
+		return count, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-s.closed:
+		return 0, internal.ErrClosed
+	}
+}
+
+// refresh 汇总整个缓存的平均访问频率、淘汰比例
+func (s *shard) refresh() {
+	s.cacheAverageQf = s.averageQf()
+	s.cacheEliminate = s.eliminateRatio(s.GetTotalCount())
+}
+
+// applyStepTime 按Controller当前的休息时间步长更新restQueue的休息时间，第n级restQueue的休息时间为 stepTime*(n+1)
+func (s *shard) applyStepTime() {
+	stepTime := s.GetStepTime()
+	for k := range s.restQueue {
+		s.restQueue[k].setRestTime(stepTime * uint32(k+1))
+	}
+}
+
+func (s *shard) setTotalCountAndTotalTime(currentCount, currentTime uint32) {
+	//
+	// if (s.TotalTime + uint64(currentTime)) > 0xffffffffffffffff {
+	//	cacheAverageQf := (s.TotalCount * internal.ScaleFactor * internal.NodeUnitRestTime) / s.TotalTime
+	//	fmt.Printf("cache等比例缩放%d(%d-%d) ==>", cacheAverageQf, s.TotalTime, s.TotalCount)
+	//	rate := float64(s.TotalTime) / float64(s.TotalCount)
+	//
+	//	s.TotalCount = uint64(float64(s.TotalCount)/rate) + uint64(currentCount)
+	//	s.TotalTime = uint64(float64(s.TotalTime)/rate) + uint64(currentTime)
+	//	cacheAverageQf = (uint64(s.TotalCount) * internal.ScaleFactor * internal.NodeUnitRestTime) / s.TotalTime
+	//	fmt.Printf("%d(%d-%d) %s \n", cacheAverageQf, s.TotalTime, s.TotalCount,s.GetQueueCount())
+	// } else {
+	//	s.TotalCount += uint64(currentCount)
+	//	s.TotalTime += uint64(currentTime)
+	// }
+
+	// 总的访问次数和总的访问qf次数（大于休息队列的最大休息时间则等比例缩放）
+	now := int64(internal.Now())
+	if now-s.updateTotalBeginTime >= int64(s.cfg.MaxTotalTime()) {
+		cacheAverageQf := s.qf(s.TotalCount, s.TotalTime)
+		internal.Logf("cache等比例缩放%d(%d-%d) ==>", cacheAverageQf, s.TotalTime, s.TotalCount)
+
+		atomic.StoreUint64(&s.TotalCount, s.TotalCount/2+uint64(currentCount))
+		atomic.StoreUint64(&s.TotalTime, s.TotalTime/2+uint64(currentTime))
+		cacheAverageQf = s.qf(s.TotalCount, s.TotalTime)
+		internal.Logf("%d(%d-%d) %s \n", cacheAverageQf, s.TotalTime, s.TotalCount, s.GetQueueCount())
+		s.updateTotalBeginTime = now
+	} else {
+		atomic.StoreUint64(&s.TotalCount, s.TotalCount+uint64(currentCount))
+		atomic.StoreUint64(&s.TotalTime, s.TotalTime+uint64(currentTime))
+	}
+}
+
+// eliminate 进行判断并做淘汰（淘汰算法在此）
+// currentCount：当前访问次数； currentRestUnit：当前node此次睡眠期间的单位时间个数
+func (s *shard) eliminate(level int, currentCount, currentTime uint64, node *internal.Node) {
+
+	// 当前node在此次睡眠期间的访问频率
+	currentQf := s.qf(currentCount, currentTime)
+
+	// 当前node整个生命周期的访问频率
+	nodeAverageQf := s.qf(uint64(node.TotalCount), uint64(node.TotalTime))
+
+	// 当前整个缓存的访问频率
+	cacheAverageQf := s.cacheAverageQf
+
+	// 计算当前node的稳定性（node在休息时间内的qf占此node的平均qf的比例）
+	var nodeStability = uint64(0)
+	if nodeAverageQf != 0 {
+		nodeStability = currentQf * internal.ScaleFactor / nodeAverageQf
+	}
+
+	// 计算出淘汰比例
+	eliminateRatio := s.cacheEliminate
+
+	var nodeEliminateRatio = uint64(0)
+	if cacheAverageQf != 0 {
+		nodeEliminateRatio = (currentQf * internal.ScaleFactor) / (cacheAverageQf * 2)
+	}
+
+	// if PrintFlag % 500000 == 0 {
+	//	fmt.Printf("==> level:%d; 当前频率 %d:(%d*20000 / %d); node频率 %d:(%d*20000 / %d); cache频率 %d:(%d*20000 / %d); " +
+	//		"稳定性:%d 淘汰比例:%d \n", level,currentQf, currentCount,currentTime, nodeAverageQf, node.TotalCount, node.TotalTime,
+	//		cacheAverageQf, s.TotalCount, s.TotalTime, nodeStability, eliminateRatio)
+	//	PrintFlag++
+	// }
+
+	node.UpdateNodeData(uint32(currentTime), s.cfg.MaxTotalTime())
+
+	// nodeStability下降50%，则判断稳定性大幅下降，判断当前node的qf是否达到淘汰比例，达到移入destroyQueue队列。
+	// 则当currentQf为0（即在当前休息时间内没有被访问），则必定移入destroyQueue队列。
+	// 固定的node不会移入destroyQueue
+	if nodeStability < *s.cfg.DestroyStability && nodeEliminateRatio <= eliminateRatio && !node.IsPinned() {
+		// fmt.Printf("%s addNode: restQueue[%d] ==> destroy, key:%d\n", time.Now().Format("15:04:05"), level, node.Hash)
+		s.destroyQueue.addNode(node)
+		atomic.AddInt32(&s.restNodeCount, -1)
+		// fmt.Printf("结果:destroyQueue \n")
+	} else {
+		// 降级处理
+
+		var levelTemp int
+		if nodeStability >= *s.cfg.StableLower && nodeStability <= s.cfg.StableUpper {
+			// 降级处理：波动在10%则上升1级
+			if level < s.cfg.LevelSize-1 {
+				levelTemp = level + 1
+			} else {
+				levelTemp = level
+			}
+		} else if nodeStability < *s.cfg.DowngradeStability {
+			// 降级处理：下降20%以上，则降级处理，多降10%则多降一级
+			levelNum := int(*s.cfg.DowngradeStability-nodeStability+90) / 100
+			if level-levelNum > 0 {
+				levelTemp = level - levelNum
+			} else {
+				levelTemp = 0
+			}
+		} else {
+			// 降级处理：下降10%到20%或者上升大于10%，则保留原级
+			levelTemp = level
+		}
+
+		// 更新cache总数
+		s.setTotalCountAndTotalTime(uint32(currentCount), uint32(currentTime))
+		s.restQueue[levelTemp].addNode(node)
+	}
+
+}
+
+// handle 分片的主循环。没有需要处理的事件时阻塞：新增的node通过UnlimitedChannel.Notify()唤醒并批量读取，
+// 休息队列有node时由粗粒度时钟（internal.Now()，与node的RestBeginTime、Expire使用同一个时间来源，包括注入的Options.Clock）
+// 变化时唤醒，检查最早到期的node是否到期，队列都为空时不唤醒，空闲的缓存不占用CPU
+func (s *shard) handle() {
+
+	// 有访问记录没有读取时，最晚一个单位时间后读取，避免访问很少时记录一直留在缓冲区中（影响单位时间内的计数）
+	var readTimer = time.NewTimer(time.Hour)
+	readTimer.Stop()
+	defer readTimer.Stop()
+	var readArmed bool
+
+	// 只有0号分片动态调整休息时间步长
+	var adjustLevelQueue <-chan time.Time
+	if s.adjust {
+		adjustLevelQueueTicker := time.NewTicker(time.Second * time.Duration(s.cfg.LevelRestStep))
+		defer adjustLevelQueueTicker.Stop()
+		adjustLevelQueue = adjustLevelQueueTicker.C
+	}
+
+	var nodes = make([]*internal.Node, 100)
+
+	for {
+		// 有node在休息时等待时间变化；最早到期的node已经到期则立即处理（先获取通知再读取时间，不会错过变化）
+		var clockChanged <-chan struct{}
+		if deadline, ok := s.nextDeadline(); ok {
+			clockChanged = internal.ClockChanged()
+			if now := internal.Now(); now >= deadline {
+				s.handleExpired(nodes, now)
+				continue
+			}
+		}
+		if !readArmed && s.reads.Pending() {
+			readTimer.Reset(time.Second * time.Duration(s.cfg.NodeUnitRestTime))
+			readArmed = true
+		}
+
+		select {
+		case <-clockChanged:
+
+			// 重新检查最早到期的node
+
+		case <-s.closed:
+
+			return
+
+		case req := <-s.evictChan:
+
+			req.result <- s.evict(req.n)
+
+		case <-adjustLevelQueue:
+
+			s.adjustEliminateParam()
+			internal.Logf("\n%s", s.GetQueueCount())
+			// fmt.Print("\n")
+
+		case <-s.stepChanged:
+
+			s.applyStepTime()
+
+		case <-s.unlimitedChannel.Notify():
+
+			s.drainNodes()
+
+		case <-s.reads.Notify():
+
+			s.drainReads()
+
+		case <-readTimer.C:
+			readArmed = false
+
+			s.drainReads()
+		}
+	}
+}
+
+// handleExpired 处理所有队列中到now为止到期的node
+func (s *shard) handleExpired(nodes []*internal.Node, now uint32) {
+	s.drainReads()
+	s.refresh()
+	// 处理初始队列
+	s.initialQueueHandle(nodes, now)
+	// 处理休息队列
+	s.restQueueHandle(nodes, now)
+	// 处理删除队列
+	s.destroyQueueHandle(nodes, now)
+}
+
+// drainNodes 把用户新增的node全部放入initialQueue
+func (s *shard) drainNodes() {
+	for {
+		node, ok := s.unlimitedChannel.GetNode()
+		if !ok {
+			return
+		}
+		s.addInitialNode(node)
+	}
+}
+
+// drainReads 读取所有的访问记录，更新node的访问次数
+func (s *shard) drainReads() {
+	s.reads.Drain(s.cfg.NodeUnitRestTime)
+}
+
+// nextDeadline 所有队列中最早到期的时间（单位为秒），所有队列都为空返回false
+func (s *shard) nextDeadline() (deadline uint32, ok bool) {
+	next := func(q *restQueue) {
+		if d, exist := q.nextDeadline(); exist && (!ok || d < deadline) {
+			deadline, ok = d, true
+		}
+	}
+
+	next(s.initialQueue)
+	next(s.destroyQueue)
+	for _, q := range s.restQueue {
+		next(q)
+	}
+	return deadline, ok
+}
+
+// 处理初始队列
+func (s *shard) initialQueueHandle(nodes []*internal.Node, now uint32) {
+	// 清空切片
+	nodes = nodes[0:0]
+
+	var currentCount uint32
+
+	nodes = s.initialQueue.getExpireNodes(now, nodes)
+
+	for k, _ := range nodes {
+
+		if s.directEliminate(nodes[k], now) {
+			// fmt.Printf("init\n")
+			continue
+		}
+
+		// 在初始队列中没有被访问，则直接淘汰（固定的或者设置了优先级的node除外）
+		if nodes[k].GetCurrentCount() == 0 && !nodes[k].IsPinned() && nodes[k].GetPriority() == 0 {
+			s.eliminateNode(nodes[k])
+			// InitDelete++
+			continue
+		}
+
+		currentCount = nodes[k].GetCurrentCount()
+
+		s.setTotalCountAndTotalTime(currentCount, s.initialQueue.restTime)
+
+		nodes[k].UpdateNodeData(s.initialQueue.restTime, s.cfg.MaxTotalTime())
+
+		// 根据优先级放入对应等级的restQueue
+		level := int(nodes[k].GetPriority())
+		if level >= len(s.restQueue) {
+			level = len(s.restQueue) - 1
+		}
+
+		// fmt.Printf("%s addNode: init ==> restQueue[%d], key:%d\n",time.Now().Format("15:04:05"), level, nodes[k].Hash)
+		s.restQueue[level].addNode(nodes[k])
+
+		atomic.AddInt32(&s.restNodeCount, 1)
+	}
+}
+
+// 处理休息队列
+func (s *shard) restQueueHandle(nodes []*internal.Node, now uint32) {
+
+	var currentCount, currentTime uint32
+
+	// 处理休息队列
+	for k, _ := range s.restQueue {
+
+		// 清空切片
+		nodes = nodes[0:0]
+
+		nodes = s.restQueue[k].getExpireNodes(now, nodes)
+
+		for kk, _ := range nodes {
+			if s.directEliminate(nodes[kk], now) {
+				// fmt.Printf("%d\n", k)
+				atomic.AddInt32(&s.restNodeCount, -1)
+				continue
+			}
+
+			currentCount = nodes[kk].GetCurrentCount()
+
+			currentTime = now - nodes[kk].RestBeginTime
+
+			// 对当前的node进行淘汰ls
+			s.eliminate(k, uint64(currentCount), uint64(currentTime), nodes[kk])
+		}
+	}
+}
+
+// 处理删除队列
+func (s *shard) destroyQueueHandle(nodes []*internal.Node, now uint32) {
+
+	// 清空切片
+	nodes = nodes[0:0]
+
+	nodes = s.destroyQueue.getExpireNodes(now, nodes)
+
+	var deleteCount = s.maxCount - s.GetTotalCount()
+	for k, _ := range nodes {
+
+		if s.directEliminate(nodes[k], now) {
+			// fmt.Printf("destroy\n")
+			continue
+		}
+
+		currentCount := uint64(nodes[k].GetCurrentCount())
+
+		// 当前node的访问频率
+		averageQf := s.qf(uint64(nodes[k].TotalCount), uint64(nodes[k].TotalTime))
+
+		// 当前node在此次睡眠期间的访问频率
+		currentQf := s.qf(currentCount, uint64(s.destroyQueue.restTime))
+
+		// node 的稳定性
+		var nodeStability = uint64(0)
+		if averageQf != 0 {
+			nodeStability = currentQf * internal.ScaleFactor / averageQf
+		}
+
+		// 对于淘汰队列中到期，需要删除的node进行捡漏：
+		// 1、在destroyQueue队列中休息期间的访问率达到此node的平均访问率；
+		// 2、在destroyQueue队列中休息期间的访问率达到此node的平均访问率的70%（RescueStability），并且整个系统没有待淘汰的数量
+		// 3、在destroyQueue队列中休息期间被固定
+		if nodeStability >= internal.ScaleFactor || (deleteCount <= 0 && nodeStability >= *s.cfg.RescueStability) ||
+			nodes[k].IsPinned() {
+
+			// fmt.Printf("%s addNode: destroy ==> restQueue[0], key:%d\n",time.Now().Format("15:04:05"), nodes[k].Hash)
+			s.setTotalCountAndTotalTime(uint32(currentCount), s.destroyQueue.restTime)
+			nodes[k].UpdateNodeData(s.destroyQueue.restTime, s.cfg.MaxTotalTime())
+			s.restQueue[0].addNode(nodes[k])
+			atomic.AddInt32(&s.restNodeCount, 1)
+		} else {
+
+			// test
+			// DeleteCount++
+			// DeleteNodeMap.Store(nodes[k].Hash, nodes[k])
+
+			// fmt.Println("delete the node: ", nodes[k].Hash)
+			s.eliminateNode(nodes[k])
+
+			deleteCount--
+		}
+	}
+}
+
+// directEliminate 进行直接淘汰：1、被外部删除；2、对象过期。
+func (s *shard) directEliminate(node *internal.Node, now uint32) (ok bool) {
+	// 被用户主动删除，直接丢弃
+	if node.IsRemoved() {
+		// 放弃管理，作为storage回收node的依据
+		node.Abandon()
+
+		// fmt.Printf("directEliminate==> 用户删除 key: %d-", node.Hash)
+
+		return true
+	}
+	// 过期，直接调用接口删除
+	if now >= node.GetExpire() {
+		s.deleteNode(node, storage.RemoveExpired)
+		// fmt.Printf("directEliminate==> 过期    key: %d-", node.Hash)
+		return true
+	}
+
+	return false
+}
+
+// deleteNode 从storage中删除node，并放弃对node的管理，node由storage回收。
+// 只删除node本身，hash对应的对象被删除后重新存储的不受影响，reason为删除的原因
+func (s *shard) deleteNode(node *internal.Node, reason storage.RemoveReason) (ok bool) {
+	ok = s.segment.Of(node.Hash).DelNode(node, reason)
+	if ok {
+		s.Release()
+	}
+	node.Abandon()
+	return ok
+}
+
+// eliminateNode 淘汰node，并记录被淘汰对象的估算访问频率
+func (s *shard) eliminateNode(node *internal.Node) (ok bool) {
+	if s.sketch != nil {
+		// victimFrequency = victimFrequency*7/8 + frequency/8，frequency放大16倍
+		// 多个分片同时更新，使用CAS避免丢失更新
+		frequency := s.sketch.Estimate(node.Hash) << 4
+		for {
+			old := atomic.LoadUint32(&s.victimFrequency)
+			if atomic.CompareAndSwapUint32(&s.victimFrequency, old, old-old/8+frequency/8) {
+				break
+			}
+		}
+	}
+
+	return s.deleteNode(node, storage.RemoveEvicted)
+}
+
+// evict 按代价从低到高依次从destroyQueue、restQueue（从低等级开始）、initialQueue的头部取出node进行淘汰
+func (s *shard) evict(n int) (count int) {
+
+	// 先把用户新增的node放入initialQueue，使其也可以被淘汰；读取访问记录，使淘汰判断使用最新的访问次数；
+	// 应用还没有处理的休息时间步长变化
+	s.drainNodes()
+	s.drainReads()
+	select {
+	case <-s.stepChanged:
+		s.applyStepTime()
+	default:
+	}
+
+	queues := make([]*restQueue, 0, len(s.restQueue)+2)
+	queues = append(queues, s.destroyQueue)
+	queues = append(queues, s.restQueue...)
+	queues = append(queues, s.initialQueue)
+
+	// 固定的node不淘汰，当前队列处理完后放回队列尾部
+	var pinned []*internal.Node
+
+	k := 0
+	for k < len(queues) && count < n {
+		node, ok := queues[k].popNode()
+		if !ok {
+			s.requeue(queues[k], pinned)
+			pinned = pinned[:0]
+			k++
+			continue
+		}
+
+		if !node.IsRemoved() && node.IsPinned() {
+			pinned = append(pinned, node)
+			continue
+		}
+
+		if k > 0 && k <= len(s.restQueue) {
+			atomic.AddInt32(&s.restNodeCount, -1)
+		}
+
+		// 被用户主动删除，直接丢弃
+		if node.IsRemoved() {
+			node.Abandon()
+			continue
+		}
+
+		if s.eliminateNode(node) {
+			count++
+		}
+	}
+
+	if len(pinned) > 0 {
+		s.requeue(queues[k], pinned)
+	}
+
+	return count
+}
+
+// requeue 把同步淘汰时跳过的固定的node放回队列尾部。队列按RestBeginTime排序，放回前结束node本次的休息
+// （与到期时相同，记入访问次数和时长），重新开始休息，避免队列头部的node还没有到期、尾部的node已经到期
+func (s *shard) requeue(q *restQueue, nodes []*internal.Node) {
+	now := internal.Now()
+	for _, node := range nodes {
+		currentTime := now - node.RestBeginTime
+		s.setTotalCountAndTotalTime(node.GetCurrentCount(), currentTime)
+		node.UpdateNodeData(currentTime, s.cfg.MaxTotalTime())
+		q.addNode(node)
+	}
+}
+
+// addInitialNode 将用户新增的node放入initialQueue
+func (s *shard) addInitialNode(node *internal.Node) {
+	// fmt.Printf("%s addNode: user ==> init, key:%d\n",time.Now().Format("15:04:05"), node.Hash)
+	node.UpdateNodeData(0, s.cfg.MaxTotalTime())
+	s.initialQueue.addNode(node)
+}
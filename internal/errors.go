@@ -0,0 +1,8 @@
+package internal
+
+import (
+	"errors"
+)
+
+// ErrClosed 缓存已经关闭
+var ErrClosed = errors.New("objectCache: 缓存已经关闭")
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// TTLJitter 过期时间的随机抖动：同时存储的大量对象的过期时间分散在一个范围内，避免在同一秒过期后同时回源。
+// 抖动只缩短过期时间，ttl秒的过期时间抖动percent%后的范围为[ttl-ttl*percent/100, ttl]。可以按topic设置不同的比例
+type TTLJitter struct {
+	// 默认的抖动比例（百分比）
+	percent uint32
+
+	// topic单独设置的抖动比例（map[uint32]uint32，topicID -> 百分比），写时复制
+	topics atomic.Value
+	lock   sync.Mutex
+}
+
+// NewTTLJitter 创建TTLJitter，percent为默认的抖动比例（百分比，[0, 100]）
+func NewTTLJitter(percent uint32) (j *TTLJitter) {
+	j = &TTLJitter{percent: percent}
+	j.topics.Store(map[uint32]uint32(nil))
+	return j
+}
+
+// SetTopic 单独设置topic的抖动比例，覆盖默认的比例
+func (j *TTLJitter) SetTopic(topicID uint32, percent uint32) {
+	j.update(func(m map[uint32]uint32) {
+		m[topicID] = percent
+	})
+}
+
+// ResetTopic 取消topic单独设置的抖动比例，恢复为默认的比例
+func (j *TTLJitter) ResetTopic(topicID uint32) {
+	j.update(func(m map[uint32]uint32) {
+		delete(m, topicID)
+	})
+}
+
+// update 复制topic的设置，修改后替换
+func (j *TTLJitter) update(fn func(m map[uint32]uint32)) {
+	j.lock.Lock()
+	old := j.topics.Load().(map[uint32]uint32)
+	m := make(map[uint32]uint32, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+	fn(m)
+	j.topics.Store(m)
+	j.lock.Unlock()
+}
+
+// Apply 对ttl（单位为秒）进行抖动，seed用于生成随机数（如对象的hash与当前时间），不需要加锁
+func (j *TTLJitter) Apply(topicID uint32, ttl uint32, seed uint64) uint32 {
+	percent := j.percent
+	if m := j.topics.Load().(map[uint32]uint32); m != nil {
+		if v, ok := m[topicID]; ok {
+			percent = v
+		}
+	}
+
+	spread := uint64(ttl) * uint64(percent) / 100
+	if spread == 0 {
+		return ttl
+	}
+	return ttl - uint32(mix64(seed)%(spread+1))
+}
+
+// mix64 splitmix64的最后一步，使相邻的seed得到分散的结果
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestTTLJitter_Total(t *testing.T) {
+	j := NewTTLJitter(10)
+
+	// 抖动只缩短过期时间，并且分散在[90, 100]之间
+	used := make(map[uint32]bool)
+	for i := uint64(0); i < 1000; i++ {
+		ttl := j.Apply(0, 100, HashFunc([]byte{byte(i), byte(i >> 8)}))
+		if ttl < 90 || ttl > 100 {
+			t.Fatal("失败1", ttl)
+		}
+		used[ttl] = true
+	}
+	if len(used) != 11 {
+		t.Error("失败2", len(used))
+	}
+
+	// 抖动范围不足1秒则不抖动
+	if j.Apply(0, 5, 12345) != 5 {
+		t.Error("失败3")
+	}
+
+	// topic单独设置
+	j.SetTopic(1, 0)
+	j.SetTopic(2, 50)
+	if j.Apply(1, 100, 12345) != 100 {
+		t.Error("失败4")
+	}
+	for i := uint64(0); i < 100; i++ {
+		if ttl := j.Apply(2, 100, i); ttl < 50 || ttl > 100 {
+			t.Fatal("失败5", ttl)
+		}
+	}
+	j.ResetTopic(1)
+	used = make(map[uint32]bool)
+	for i := uint64(0); i < 100; i++ {
+		used[j.Apply(1, 100, i)] = true
+	}
+	if len(used) == 1 {
+		t.Error("失败6")
+	}
+}
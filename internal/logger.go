@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Logger 输出controller的统计信息等日志，*log.Logger满足此接口
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// stdoutLogger 输出到标准输出（默认）
+type stdoutLogger struct{}
+
+func (stdoutLogger) Printf(format string, v ...interface{}) {
+	fmt.Printf(format, v...)
+}
+
+// loggerValue 保存到atomic.Value中的值需要是同一类型
+type loggerValue struct {
+	Logger
+}
+
+var logger atomic.Value
+
+func init() {
+	logger.Store(loggerValue{stdoutLogger{}})
+}
+
+// SetLogger 设置日志输出，为nil则恢复为标准输出
+func SetLogger(l Logger) {
+	if l == nil {
+		l = stdoutLogger{}
+	}
+	logger.Store(loggerValue{l})
+}
+
+// Logf 输出日志
+func Logf(format string, v ...interface{}) {
+	logger.Load().(loggerValue).Printf(format, v...)
+}
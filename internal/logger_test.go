@@ -0,0 +1,25 @@
+package internal
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestLogger_Total(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogger(log.New(&buf, "", 0))
+	defer SetLogger(nil)
+
+	Logf("count: %d", 10)
+	if buf.String() != "count: 10\n" {
+		t.Error("失败1", buf.String())
+	}
+
+	// 恢复为标准输出
+	SetLogger(nil)
+	Logf("")
+	if buf.Len() != len("count: 10\n") {
+		t.Error("失败2")
+	}
+}
@@ -2,12 +2,34 @@ package internal
 
 import (
 	"sync/atomic"
-	"time"
 )
 
-const ()
+const (
+	// 固定的node不会被淘汰（仍然会过期）
+	nodeFlagPinned = uint32(1)
+	// 不纳入淘汰管理的node（SetDirect()存储），过期后直接回收，不经过controller
+	nodeFlagDirect = uint32(2)
+	// 已经从storage中删除（被用户删除、过期或者被淘汰）
+	nodeFlagRemoved = uint32(4)
+	// controller已经放弃管理，此后node可以被storage重新使用
+	nodeFlagAbandoned = uint32(8)
+	// flags中优先级的偏移，优先级占8位
+	nodePriorityShift = 8
+	// flags中所在淘汰队列的偏移，占8位
+	nodeQueueShift = 16
+)
+
+// node所在的淘汰队列，见Node.SetQueue()
+const (
+	// 还没有放入淘汰队列（在controller的积压中），或者不纳入淘汰管理
+	QueueNone = uint8(iota)
+	QueueInitial
+	QueueDestroy
+	// 第n级restQueue为QueueRest+n
+	QueueRest
+)
 
-// 存储的基本单元(sizeof = 48)
+// 存储的基本单元(sizeof = 80)
 type Node struct {
 	// 最后被访问的时间，单位为秒
 	LastReadTime uint32
@@ -24,6 +46,25 @@ type Node struct {
 
 	// 过期时间，单位为秒，Unix time
 	Expire uint32
+	// 存储时设置的过期时间（单位为秒，不包括随机抖动），滑动过期时读取后把Expire推迟到当前时间+TTL，为0则不过期
+	TTL uint32
+	// 时间轮中最后加入的项的过期时间，为0则没有有效的项，见internal.TimingWheel.Schedule()
+	WheelExpire uint32
+
+	// 低8位为标志位，8~15位为优先级，16~23位为所在的淘汰队列
+	flags uint32
+
+	// 对象所属topic的编号，用于按topic删除对象
+	TopicID uint32
+
+	// 序列化存储模式下对象在字节区中的偏移和长度
+	Offset uint32
+	Length uint32
+
+	// 在分段的nodePool中的下标
+	Index uint32
+	// 代数，node每次从storage中删除加1，持有node的一方（如无锁读取）据此判断node是否已经被删除或重新使用
+	gen uint32
 
 	// hash 值
 	Hash uint64
@@ -41,26 +82,28 @@ type Node struct {
 // }
 
 // UpdateNodeData 当node从休息队列中取出来后更新RestUnitCount、currentCount
-func (n *Node) UpdateNodeData(CurrentTime uint32) {
+// maxTotalTime 为统计访问频率的最近期限，见EvictionConfig.MaxTotalTime
+func (n *Node) UpdateNodeData(CurrentTime uint32, maxTotalTime uint32) {
 
-	// 设置totalCount
-	n.TotalCount += n.GetCurrentCount()
-	// 设置TotalRestUnitTime
-	n.TotalTime += CurrentTime
+	// 只由controller修改，使用原子操作写入，其他协程通过Meta()读取
+	totalCount := n.TotalCount + n.GetCurrentCount()
+	totalTime := n.TotalTime + CurrentTime
 
 	// TotalTime、TotalCount是用于计算最近访问频率，这个最近的期限定为restQueue休息的最大时间，当超过这个时间就等比例缩放1倍
-	if uint64(n.TotalTime) >= LevelRestStep*LevelSize {
+	if totalTime >= maxTotalTime {
 
 		// nodeAverageQf := uint64(n.TotalCount) * 1000 * NodeUnitRestTime / uint64(n.TotalTime)
 
 		// fmt.Printf("node等比例缩放%d(%d-%d) ==>", nodeAverageQf, n.TotalTime, n.TotalCount)
-		n.TotalTime = n.TotalTime / 2
-		n.TotalCount = n.TotalCount / 2
+		totalTime = totalTime / 2
+		totalCount = totalCount / 2
 		// nodeAverageQf = uint64(n.TotalCount) * 1000 * NodeUnitRestTime / uint64(n.TotalTime)
 		// fmt.Printf("%d(%d-%d) \n", nodeAverageQf, n.TotalTime, n.TotalCount)
 	}
 
-	n.RestBeginTime = uint32(time.Now().Unix())
+	atomic.StoreUint32(&n.TotalCount, totalCount)
+	atomic.StoreUint32(&n.TotalTime, totalTime)
+	atomic.StoreUint32(&n.RestBeginTime, Now())
 	atomic.StoreUint32(&n.currentCount, 0)
 
 }
@@ -75,18 +118,157 @@ func (n *Node) AddCurrentCount(count uint32) {
 }
 
 func (n *Node) InitReadCount() {
-	n.currentCount = 0
+	atomic.StoreUint32(&n.currentCount, 0)
+}
+
+// IncrementReadCount 增加访问次数，unitRestTime为被访问的单位时间（单位为秒）
+func (n *Node) IncrementReadCount(unitRestTime uint32) (ok bool) {
+	return n.IncrementReadCountAt(Now(), unitRestTime)
 }
 
-func (n *Node) IncrementReadCount() (ok bool) {
+// IncrementReadCountAt 与IncrementReadCount相同，now为访问的时间（单位为秒），批量记录时使用同一个时间
+func (n *Node) IncrementReadCountAt(now uint32, unitRestTime uint32) (ok bool) {
 
 	// 在单位时间内，被访问多次只计算1次
-	now := uint32(time.Now().Unix())
-	if (now - n.LastReadTime) >= NodeUnitRestTime {
+	// 并发访问时只有更新LastReadTime成功的一次计数
+	last := atomic.LoadUint32(&n.LastReadTime)
+	if now-last >= unitRestTime && atomic.CompareAndSwapUint32(&n.LastReadTime, last, now) {
 		atomic.AddUint32(&n.currentCount, 1)
-		n.LastReadTime = now
 		return true
 	}
 
 	return false
 }
+
+// GetExpire 获取过期时间，controller不加锁读取
+func (n *Node) GetExpire() uint32 {
+	return atomic.LoadUint32(&n.Expire)
+}
+
+// SetExpire 设置过期时间，由storage加锁调用
+func (n *Node) SetExpire(expire uint32) {
+	atomic.StoreUint32(&n.Expire, expire)
+}
+
+// Gen 获取代数
+func (n *Node) Gen() uint32 {
+	return atomic.LoadUint32(&n.gen)
+}
+
+// Remove 标记node已经从storage中删除，并增加代数，由storage加锁调用
+func (n *Node) Remove() {
+	atomic.AddUint32(&n.gen, 1)
+	n.setFlag(nodeFlagRemoved, true)
+}
+
+func (n *Node) IsRemoved() bool {
+	return atomic.LoadUint32(&n.flags)&nodeFlagRemoved != 0
+}
+
+// Abandon controller放弃管理node，调用后controller不能再访问此node
+func (n *Node) Abandon() {
+	n.setFlag(nodeFlagAbandoned, true)
+}
+
+func (n *Node) IsAbandoned() bool {
+	return atomic.LoadUint32(&n.flags)&nodeFlagAbandoned != 0
+}
+
+// ResetFlags 清除标志位和优先级，node被重新使用时调用
+func (n *Node) ResetFlags() {
+	atomic.StoreUint32(&n.flags, 0)
+}
+
+// setFlag 设置或清除标志位
+func (n *Node) setFlag(flag uint32, on bool) {
+	for {
+		old := atomic.LoadUint32(&n.flags)
+		flags := old &^ flag
+		if on {
+			flags |= flag
+		}
+		if atomic.CompareAndSwapUint32(&n.flags, old, flags) {
+			return
+		}
+	}
+}
+
+// SetPinned 设置node是否固定，固定的node不会被淘汰
+func (n *Node) SetPinned(pinned bool) {
+	n.setFlag(nodeFlagPinned, pinned)
+}
+
+func (n *Node) IsPinned() bool {
+	return atomic.LoadUint32(&n.flags)&nodeFlagPinned != 0
+}
+
+// SetDirect 设置node是否不纳入淘汰管理
+func (n *Node) SetDirect(direct bool) {
+	n.setFlag(nodeFlagDirect, direct)
+}
+
+func (n *Node) IsDirect() bool {
+	return atomic.LoadUint32(&n.flags)&nodeFlagDirect != 0
+}
+
+// SetPriority 设置优先级，优先级为node离开initialQueue后放入的restQueue等级
+func (n *Node) SetPriority(priority uint8) {
+	for {
+		old := atomic.LoadUint32(&n.flags)
+		flags := old&^(0xff<<nodePriorityShift) | uint32(priority)<<nodePriorityShift
+		if atomic.CompareAndSwapUint32(&n.flags, old, flags) {
+			return
+		}
+	}
+}
+
+func (n *Node) GetPriority() uint8 {
+	return uint8(atomic.LoadUint32(&n.flags) >> nodePriorityShift)
+}
+
+// SetQueue 设置node所在的淘汰队列（QueueInitial等），由controller放入队列时调用
+func (n *Node) SetQueue(queue uint8) {
+	for {
+		old := atomic.LoadUint32(&n.flags)
+		flags := old&^(0xff<<nodeQueueShift) | uint32(queue)<<nodeQueueShift
+		if old == flags || atomic.CompareAndSwapUint32(&n.flags, old, flags) {
+			return
+		}
+	}
+}
+
+// GetQueue 获取node所在的淘汰队列
+func (n *Node) GetQueue() uint8 {
+	return uint8(atomic.LoadUint32(&n.flags) >> nodeQueueShift)
+}
+
+// NodeMeta node的淘汰状态的快照，见Meta()
+type NodeMeta struct {
+	Expire        uint32
+	LastReadTime  uint32
+	RestBeginTime uint32
+	CurrentCount  uint32
+	TotalCount    uint32
+	TotalTime     uint32
+	Queue         uint8
+	Priority      uint8
+	Pinned        bool
+	Direct        bool
+}
+
+// Meta 读取node的淘汰状态，可以在controller以外的协程中调用（各字段分别原子读取，不是一致的快照）
+func (n *Node) Meta() (m NodeMeta) {
+	flags := atomic.LoadUint32(&n.flags)
+	return NodeMeta{
+		Expire:        atomic.LoadUint32(&n.Expire),
+		LastReadTime:  atomic.LoadUint32(&n.LastReadTime),
+		RestBeginTime: atomic.LoadUint32(&n.RestBeginTime),
+		CurrentCount:  atomic.LoadUint32(&n.currentCount),
+		TotalCount:    atomic.LoadUint32(&n.TotalCount),
+		TotalTime:     atomic.LoadUint32(&n.TotalTime),
+		Queue:         uint8(flags >> nodeQueueShift),
+		Priority:      uint8(flags >> nodePriorityShift),
+		Pinned:        flags&nodeFlagPinned != 0,
+		Direct:        flags&nodeFlagDirect != 0,
+	}
+}
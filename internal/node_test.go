@@ -7,7 +7,7 @@ import (
 
 func TestNode_GetCurrentCount(t *testing.T) {
 	var n = Node{}
-	n.IncrementReadCount()
+	n.IncrementReadCount(NodeUnitRestTime)
 	n.AddCurrentCount(5)
 	n.TotalCount = 1000
 
@@ -15,7 +15,7 @@ func TestNode_GetCurrentCount(t *testing.T) {
 		t.Error("失败1")
 	}
 
-	n.UpdateNodeData(600)
+	n.UpdateNodeData(600, uint32(LevelRestStep*LevelSize))
 
 	if n.GetCurrentCount() != 0 {
 		t.Error("失败3")
@@ -26,7 +26,7 @@ func TestNode_GetCurrentCount(t *testing.T) {
 
 	n.AddCurrentCount(9)
 
-	n.UpdateNodeData(600)
+	n.UpdateNodeData(600, uint32(LevelRestStep*LevelSize))
 
 	if n.GetCurrentCount() != 0 {
 		t.Error("失败5")
@@ -39,13 +39,13 @@ func TestNode_GetCurrentCount(t *testing.T) {
 
 func TestNode_IncrementReadCount(t *testing.T) {
 	var n = Node{}
-	n.LastReadTime = uint32(time.Now().Unix())
-	n.IncrementReadCount()
+	n.LastReadTime = Now()
+	n.IncrementReadCount(NodeUnitRestTime)
 
 	time.Sleep(time.Second * 10)
 
-	n.IncrementReadCount()
-	n.IncrementReadCount()
+	n.IncrementReadCount(NodeUnitRestTime)
+	n.IncrementReadCount(NodeUnitRestTime)
 
 	nn := n.GetCurrentCount()
 
@@ -53,3 +53,39 @@ func TestNode_IncrementReadCount(t *testing.T) {
 		t.Error("失败")
 	}
 }
+
+func TestNode_Flags(t *testing.T) {
+	var n = Node{}
+	n.SetPriority(3)
+	n.SetPinned(true)
+
+	if !n.IsPinned() || n.GetPriority() != 3 {
+		t.Error("失败1")
+	}
+
+	n.SetPinned(false)
+	if n.IsPinned() || n.GetPriority() != 3 {
+		t.Error("失败2")
+	}
+
+	n.SetDirect(true)
+	if !n.IsDirect() || n.IsPinned() || n.GetPriority() != 3 {
+		t.Error("失败3")
+	}
+
+	// 所在的淘汰队列不影响其他标志位
+	n.SetQueue(QueueRest + 5)
+	if n.GetQueue() != QueueRest+5 || !n.IsDirect() || n.GetPriority() != 3 {
+		t.Error("失败5")
+	}
+	m := n.Meta()
+	if m.Queue != QueueRest+5 || !m.Direct || m.Pinned || m.Priority != 3 {
+		t.Error("失败6", m)
+	}
+
+	n.SetPinned(true)
+	n.ResetFlags()
+	if n.IsPinned() || n.IsDirect() || n.GetPriority() != 0 || n.GetQueue() != QueueNone {
+		t.Error("失败4")
+	}
+}
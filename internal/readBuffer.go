@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// 每个环形缓冲区的容量（2的幂）
+const readStripeSize = 16
+
+// readStripe 一个有损的环形缓冲区：写入方通过CAS占用tail的位置，缓冲区满时直接丢弃；只有一个读取方，读取后推进head。
+// head、tail分别放在不同的cache line，避免写入方与读取方之间的伪共享
+type readStripe struct {
+	tail uint32
+	_    [60]byte
+	head uint32
+	_    [60]byte
+	buf  [readStripeSize]unsafe.Pointer
+}
+
+// ReadBuffer 记录对象被访问的缓冲区，由多个readStripe组成，每次写入随机选择一个（随机数不加锁，个数不少于P的个数，减少写入方之间的竞争）。
+// 读取时只把node写入缓冲区，不读取时间、不修改node；由controller批量读取后更新node的访问次数，热点对象的node不会在多个CPU之间
+// 来回同步。缓冲区满时丢弃访问记录（访问次数只用于淘汰判断，少量丢失不影响结果）
+type ReadBuffer struct {
+	stripes []readStripe
+	mask    uint32
+
+	// 缓冲区达到一半时通知读取方，容量为1
+	notify chan struct{}
+}
+
+// NewReadBuffer 创建ReadBuffer，缓冲区个数为GOMAXPROCS向上取2的幂
+func NewReadBuffer() (b *ReadBuffer) {
+	count := 1
+	for count < runtime.GOMAXPROCS(0) {
+		count <<= 1
+	}
+	return &ReadBuffer{
+		stripes: make([]readStripe, count),
+		mask:    uint32(count - 1),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// Record 记录node被访问一次，缓冲区满时丢弃并返回false
+func (b *ReadBuffer) Record(n *Node) (ok bool) {
+	s := &b.stripes[rand.Uint32()&b.mask]
+
+	tail := atomic.LoadUint32(&s.tail)
+	size := tail - atomic.LoadUint32(&s.head)
+	if size >= readStripeSize || !atomic.CompareAndSwapUint32(&s.tail, tail, tail+1) {
+		return false
+	}
+	atomic.StorePointer(&s.buf[tail&(readStripeSize-1)], unsafe.Pointer(n))
+
+	if size+1 == readStripeSize/2 && len(b.notify) == 0 {
+		select {
+		case b.notify <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// Notify 返回缓冲区达到一半时通知的channel，收到通知后应当调用Drain()
+func (b *ReadBuffer) Notify() <-chan struct{} {
+	return b.notify
+}
+
+// Pending 是否有还没有读取的访问记录
+func (b *ReadBuffer) Pending() (ok bool) {
+	for k := range b.stripes {
+		s := &b.stripes[k]
+		if atomic.LoadUint32(&s.tail) != atomic.LoadUint32(&s.head) {
+			return true
+		}
+	}
+	return false
+}
+
+// Drain 读取所有的访问记录，更新node的访问次数，返回读取的个数。只能由一个协程调用。
+// 所有记录使用同一个时间，unitRestTime为被访问的单位时间（单位为秒）
+func (b *ReadBuffer) Drain(unitRestTime uint32) (count int) {
+	now := Now()
+	for k := range b.stripes {
+		s := &b.stripes[k]
+		head := atomic.LoadUint32(&s.head)
+		tail := atomic.LoadUint32(&s.tail)
+		for ; head != tail; head++ {
+			slot := &s.buf[head&(readStripeSize-1)]
+			p := atomic.LoadPointer(slot)
+			if p == nil {
+				// 写入方已经占用位置但还没有写入，下次再读取
+				break
+			}
+			atomic.StorePointer(slot, nil)
+			// 记录后node可能已经被删除或重新使用，被删除的忽略，重新使用的计入新的对象（只是少量偏差）
+			if n := (*Node)(p); !n.IsRemoved() {
+				n.IncrementReadCountAt(now, unitRestTime)
+			}
+			count++
+		}
+		atomic.StoreUint32(&s.head, head)
+	}
+	return count
+}
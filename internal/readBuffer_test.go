@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadBuffer_RecordAndDrain(t *testing.T) {
+	b := NewReadBuffer()
+	n := &Node{}
+
+	if b.Pending() || b.Drain(NodeUnitRestTime) != 0 {
+		t.Fatal("失败1")
+	}
+
+	// 同一个单位时间内多次访问只计数1次
+	b.Record(n)
+	b.Record(n)
+	if !b.Pending() {
+		t.Error("失败2")
+	}
+	if count := b.Drain(NodeUnitRestTime); count != 2 || n.GetCurrentCount() != 1 {
+		t.Error("失败3", count, n.GetCurrentCount())
+	}
+
+	// 被删除的node不计数
+	removed := &Node{}
+	removed.Remove()
+	b.Record(removed)
+	b.Drain(NodeUnitRestTime)
+	if removed.GetCurrentCount() != 0 {
+		t.Error("失败4")
+	}
+}
+
+func TestReadBuffer_Lossy(t *testing.T) {
+	b := NewReadBuffer()
+	n := &Node{}
+
+	// 连续写入，所有缓冲区满后丢弃
+	var recorded int
+	for i := 0; i < readStripeSize*len(b.stripes)*2; i++ {
+		if b.Record(n) {
+			recorded++
+		}
+	}
+	if recorded > readStripeSize*len(b.stripes) {
+		t.Error("失败1", recorded)
+	}
+
+	// 达到一半时通知
+	select {
+	case <-b.Notify():
+	case <-time.After(time.Second):
+		t.Error("失败2")
+	}
+
+	if count := b.Drain(NodeUnitRestTime); count != recorded || b.Pending() {
+		t.Error("失败3", count, recorded)
+	}
+}
+
+// TestReadBuffer_Concurrent 并发写入和读取，需要使用 go test -race 运行
+func TestReadBuffer_Concurrent(t *testing.T) {
+	b := NewReadBuffer()
+	nodes := make([]*Node, 16)
+	for k := range nodes {
+		nodes[k] = &Node{}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100000; j++ {
+				b.Record(nodes[j%len(nodes)])
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			b.Drain(NodeUnitRestTime)
+			if b.Pending() {
+				t.Error("失败1")
+			}
+			return
+		default:
+			b.Drain(NodeUnitRestTime)
+		}
+	}
+}
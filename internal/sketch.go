@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"sync/atomic"
+)
+
+// 每个hash在sketch中对应的计数器个数
+const sketchDepth = 4
+
+// 计算计数器位置时使用的种子
+var sketchSeeds = [sketchDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+// FrequencySketch 用于估算访问频率的count-min sketch，准入策略（TinyLFU）使用。
+// 每个计数器为4bit（上限15），一个uint64存储16个计数器；累计记录次数达到sampleSize后所有计数器减半，
+// 这样估算出来的是最近一段时间的访问频率。所有方法都可以并发调用。
+type FrequencySketch struct {
+	table []uint64
+	mask  uint64
+
+	// 上次衰减后的记录次数
+	additions  int32
+	sampleSize int32
+	resetting  int32
+}
+
+// NewFrequencySketch 根据需要统计的对象数量创建sketch
+func NewFrequencySketch(capacity int32) (s *FrequencySketch) {
+	if capacity < 64 {
+		capacity = 64
+	}
+
+	// 每个对象约4个计数器
+	size := uint64(1)
+	for size*16 < uint64(capacity)*4 {
+		size <<= 1
+	}
+
+	sampleSize := int64(capacity) * 10
+	if sampleSize > 1<<30 {
+		sampleSize = 1 << 30
+	}
+
+	return &FrequencySketch{
+		table:      make([]uint64, size),
+		mask:       size - 1,
+		sampleSize: int32(sampleSize),
+	}
+}
+
+// position 计算第i个计数器所在的下标以及在uint64中的偏移
+func (s *FrequencySketch) position(hash uint64, i int) (index uint64, offset uint64) {
+	h := hash + sketchSeeds[i]
+	h = (h ^ (h >> 33)) * 0xff51afd7ed558ccd
+	h = (h ^ (h >> 33)) * 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return (h >> 4) & s.mask, (h & 15) << 2
+}
+
+// Increment 记录一次访问
+func (s *FrequencySketch) Increment(hash uint64) {
+	var added bool
+	for i := 0; i < sketchDepth; i++ {
+		index, offset := s.position(hash, i)
+		for {
+			old := atomic.LoadUint64(&s.table[index])
+			if (old>>offset)&15 == 15 {
+				break
+			}
+			if atomic.CompareAndSwapUint64(&s.table[index], old, old+(1<<offset)) {
+				added = true
+				break
+			}
+		}
+	}
+
+	if added && atomic.AddInt32(&s.additions, 1) >= s.sampleSize {
+		s.reset()
+	}
+}
+
+// Estimate 估算访问频率（[0, 15]）
+func (s *FrequencySketch) Estimate(hash uint64) (frequency uint32) {
+	frequency = 15
+	for i := 0; i < sketchDepth; i++ {
+		index, offset := s.position(hash, i)
+		count := uint32((atomic.LoadUint64(&s.table[index]) >> offset) & 15)
+		if count < frequency {
+			frequency = count
+		}
+	}
+	return frequency
+}
+
+// reset 衰减：所有计数器减半
+func (s *FrequencySketch) reset() {
+	if !atomic.CompareAndSwapInt32(&s.resetting, 0, 1) {
+		return
+	}
+
+	for k := range s.table {
+		for {
+			old := atomic.LoadUint64(&s.table[k])
+			if atomic.CompareAndSwapUint64(&s.table[k], old, (old>>1)&0x7777777777777777) {
+				break
+			}
+		}
+	}
+	atomic.StoreInt32(&s.additions, atomic.LoadInt32(&s.additions)/2)
+
+	atomic.StoreInt32(&s.resetting, 0)
+}
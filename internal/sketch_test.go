@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestFrequencySketch_Total(t *testing.T) {
+
+	s := NewFrequencySketch(1000)
+
+	if s.Estimate(HashFunc([]byte("a"))) != 0 {
+		t.Error("失败1")
+	}
+
+	hash := HashFunc([]byte("hot"))
+	for i := 0; i < 5; i++ {
+		s.Increment(hash)
+	}
+	if s.Estimate(hash) != 5 {
+		t.Error("失败2", s.Estimate(hash))
+	}
+
+	// 计数器上限为15
+	for i := 0; i < 20; i++ {
+		s.Increment(hash)
+	}
+	if s.Estimate(hash) != 15 {
+		t.Error("失败3", s.Estimate(hash))
+	}
+
+	// 达到sampleSize后衰减
+	for i := 0; i < int(s.sampleSize); i++ {
+		s.Increment(uint64(i) + 1<<40)
+	}
+	if s.Estimate(hash) > 7 {
+		t.Error("失败4", s.Estimate(hash))
+	}
+}
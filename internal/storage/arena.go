@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"sync/atomic"
+)
+
+const (
+	// 字节区的默认初始大小
+	DefaultArenaSize = 64 * 1024
+	// 字节区的最大大小，node中的偏移为uint32
+	maxArenaSize = 1<<32 - 1
+)
+
+// 所有堆外字节区的大小
+var offHeapBytes int64
+
+// OffHeapBytes 当前使用mmap分配的堆外字节区的总大小
+func OffHeapBytes() int64 {
+	return atomic.LoadInt64(&offHeapBytes)
+}
+
+// arena 存储序列化后对象的字节区（只追加），node只记录对象在字节区中的偏移和长度，字节区本身不包含指针，GC不需要扫描。
+// 删除的对象只记录失效的字节数，空间不足或者失效的数据过多时由Storage整理（只复制有效的对象到新的字节区），
+// 并根据有效数据的大小扩大或缩小字节区，原来的字节区被释放。
+// 堆外模式下字节区使用匿名mmap分配（不在Go堆上），整理后立即munmap原来的字节区，内存直接归还给操作系统。
+// arena不是并发安全的，由Storage加锁。
+type arena struct {
+	buf []byte
+	// 已经使用的字节数
+	used uint32
+	// 失效（对象被删除或者更新）的字节数
+	dead uint32
+	// 初始大小，缩小时不小于此值
+	initSize uint32
+
+	// 是否使用堆外字节区
+	offHeap bool
+	// buf是否由mmap分配（mmap失败时使用Go堆）
+	mapped bool
+}
+
+func newArena(size uint32, offHeap bool) (a *arena) {
+	if size == 0 {
+		size = DefaultArenaSize
+	}
+	a = &arena{initSize: size, offHeap: offHeap}
+	a.allocBuffer(uint64(size))
+	return a
+}
+
+// allocBuffer 分配大小为size的字节区
+func (a *arena) allocBuffer(size uint64) {
+	if a.offHeap {
+		buf, err := mmapBuffer(int(size))
+		if err == nil {
+			a.buf, a.mapped = buf, true
+			atomic.AddInt64(&offHeapBytes, int64(size))
+			return
+		}
+	}
+	a.buf, a.mapped = make([]byte, size), false
+}
+
+// resize 创建一个大小为size、配置相同的空字节区
+func (a *arena) resize(size uint64) (na *arena) {
+	na = &arena{initSize: a.initSize, offHeap: a.offHeap}
+	na.allocBuffer(size)
+	return na
+}
+
+// release 释放字节区，之后不能再访问
+func (a *arena) release() {
+	if a.mapped {
+		atomic.AddInt64(&offHeapBytes, -int64(len(a.buf)))
+		_ = munmapBuffer(a.buf)
+	}
+	a.buf = nil
+	a.used, a.dead = 0, 0
+}
+
+// alloc 追加data，空间不足返回false
+func (a *arena) alloc(data []byte) (offset uint32, ok bool) {
+	if uint64(a.used)+uint64(len(data)) > uint64(len(a.buf)) {
+		return 0, false
+	}
+	offset = a.used
+	a.used += uint32(copy(a.buf[offset:], data))
+	return offset, true
+}
+
+// fits 整理后是否有空间追加size字节，freed为同时失效的字节数（更新的对象原来的数据）
+func (a *arena) fits(size, freed uint32) bool {
+	return uint64(a.live())-uint64(freed)+uint64(size) <= maxArenaSize
+}
+
+// free 记录失效的字节数
+func (a *arena) free(length uint32) {
+	a.dead += length
+}
+
+// bytes 返回对象的数据（不复制）
+func (a *arena) bytes(offset, length uint32) []byte {
+	return a.buf[offset : offset+length]
+}
+
+// live 有效的字节数
+func (a *arena) live() uint32 {
+	return a.used - a.dead
+}
+
+// shouldShrink 失效的数据多于有效的数据，并且整理后字节区可以缩小
+func (a *arena) shouldShrink() bool {
+	return a.dead > a.live() && uint32(len(a.buf)) > a.initSize && uint64(a.live())*4 < uint64(len(a.buf))
+}
+
+// newSize 整理时字节区的大小：有效数据加上need超过一半则扩大，不足四分之一则缩小
+func (a *arena) newSize(need uint32) (size uint64) {
+	size = uint64(len(a.buf))
+	required := uint64(a.live()) + uint64(need)
+	for required*2 > size {
+		size *= 2
+	}
+	for size > uint64(a.initSize) && required*4 < size {
+		size /= 2
+	}
+	if size > maxArenaSize {
+		size = maxArenaSize
+	}
+	return size
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"bytes"
+	"objectCache/internal"
+	"testing"
+)
+
+func TestStorage_Arena(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+	s.EnableArena(1024, false)
+
+	value := func(i int) []byte {
+		return bytes.Repeat([]byte{byte(i)}, 100)
+	}
+
+	// 超出初始大小后整理并扩大字节区
+	for i := 1; i <= 100; i++ {
+		if _, ok := s.Set(value(i), uint64(i), 0, 0); !ok {
+			t.Error("失败1")
+		}
+	}
+	size, used, dead := s.ArenaStats()
+	if size < 100*100 || used != 100*100 || dead != 0 {
+		t.Error("失败2", size, used, dead)
+	}
+
+	for i := 1; i <= 100; i++ {
+		n, obj, _, ok := s.GetValue(uint64(i))
+		if !ok || !bytes.Equal(obj.([]byte), value(i)) || n.Obj == nil {
+			t.Error("失败3")
+		}
+	}
+
+	// 更新的对象原来的数据失效
+	s.Set(value(200), 1, 0, 0)
+	if _, obj, _, _ := s.GetValue(1); !bytes.Equal(obj.([]byte), value(200)) {
+		t.Error("失败4")
+	}
+	if _, _, dead = s.ArenaStats(); dead != 100 {
+		t.Error("失败5", dead)
+	}
+
+	// 删除后整理时释放空间，字节区缩小
+	for i := 1; i <= 95; i++ {
+		n, ok := s.Del(uint64(i))
+		if !ok || n.Obj != nil || n.Length != 0 {
+			t.Error("失败6")
+		}
+	}
+	s.Set(value(1), 1, 0, 0)
+	for i := 0; i < 100; i++ {
+		s.Set(value(i), 1, 0, 0)
+	}
+	newSize, used, dead := s.ArenaStats()
+	if newSize >= size || used-dead != 6*100 {
+		t.Error("失败7", newSize, used, dead)
+	}
+	for i := 96; i <= 100; i++ {
+		if _, obj, _, ok := s.GetValue(uint64(i)); !ok || !bytes.Equal(obj.([]byte), value(i)) {
+			t.Error("失败8")
+		}
+	}
+}
+
+func TestStorage_ArenaFull(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+	s.EnableArena(1024, false)
+	s.Set(bytes.Repeat([]byte{1}, 100), 1, 0, 0)
+
+	// 模拟有效数据接近最大大小，整理后仍没有空间则不存储，也不修改原有的对象
+	s.arena.used = maxArenaSize - 50
+	if n, ok := s.Set(make([]byte, 100), 2, 0, 0); n != nil || ok || s.Has(2) {
+		t.Error("失败1")
+	}
+	if n, ok := s.Update(make([]byte, 200), 1, 0); n != nil || !ok {
+		t.Error("失败2")
+	}
+	if n, ok := s.SetDirect(make([]byte, 200), 1, 0, 0); n != nil || ok {
+		t.Error("失败3")
+	}
+	if _, obj, _, ok := s.GetValue(1); !ok || !bytes.Equal(obj.([]byte), bytes.Repeat([]byte{1}, 100)) {
+		t.Error("失败4")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build linux
+// +build linux
+
+package storage
+
+import (
+	"syscall"
+)
+
+// OffHeapSupported 当前平台是否支持堆外字节区
+const OffHeapSupported = true
+
+// mmapBuffer 使用匿名mmap分配size字节，不在Go堆上，GC不扫描也不计入堆大小
+func mmapBuffer(size int) (buf []byte, err error) {
+	return syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+// munmapBuffer 释放mmapBuffer分配的内存，之后不能再访问buf
+func munmapBuffer(buf []byte) (err error) {
+	return syscall.Munmap(buf)
+}
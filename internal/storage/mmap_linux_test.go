@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package storage
+
+import (
+	"bytes"
+	"objectCache/internal"
+	"testing"
+)
+
+func TestStorage_OffHeap(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+	base := OffHeapBytes()
+	s.EnableArena(64*1024, true)
+
+	if !s.arena.mapped || OffHeapBytes()-base != 64*1024 {
+		t.Fatal("失败1")
+	}
+
+	value := bytes.Repeat([]byte{1}, 1024)
+	for i := 1; i <= 10000; i++ {
+		s.Set(value, uint64(i), 0, 0)
+	}
+	peak := OffHeapBytes() - base
+	if peak < 10000*1024 {
+		t.Error("失败2", peak)
+	}
+	for i := 1; i <= 10000; i += 1000 {
+		if _, obj, _, ok := s.GetValue(uint64(i)); !ok || !bytes.Equal(obj.([]byte), value) {
+			t.Error("失败3")
+		}
+	}
+
+	// 淘汰（删除）后释放内存
+	for i := 1; i <= 9990; i++ {
+		s.Del(uint64(i))
+	}
+	released := OffHeapBytes() - base
+	if released*16 > peak {
+		t.Error("失败4", peak, released)
+	}
+	for i := 9991; i <= 10000; i++ {
+		if _, obj, _, ok := s.GetValue(uint64(i)); !ok || !bytes.Equal(obj.([]byte), value) {
+			t.Error("失败5")
+		}
+	}
+
+	size, used, dead := s.ArenaStats()
+	if int64(size) != released || used-dead != 10*1024 {
+		t.Error("失败6", size, used, dead)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package storage
+
+import (
+	"errors"
+)
+
+// OffHeapSupported 当前平台是否支持堆外字节区
+const OffHeapSupported = false
+
+func mmapBuffer(size int) (buf []byte, err error) {
+	return nil, errors.New("storage: 当前平台不支持堆外字节区")
+}
+
+func munmapBuffer(buf []byte) (err error) {
+	return nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"math/bits"
+	"objectCache/internal"
+)
+
+const (
+	// 每块node个数的位数（1024个）
+	nodeChunkBits = 10
+	nodeChunkSize = 1 << nodeChunkBits
+	nodeChunkMask = nodeChunkSize - 1
+
+	// 前nodeChunkSize个node使用的块从16个开始成倍增大（16、16、32 ... 512），对象很少的分段不需要分配整块
+	nodeChunkMinBits = 4
+	nodeChunkMinSize = 1 << nodeChunkMinBits
+	// 成倍增大的块的个数
+	nodeSmallChunks = nodeChunkBits - nodeChunkMinBits + 1
+)
+
+// nodePool 分段内node的分配与回收。
+// node按块分配，块一旦分配不会移动，node的指针在整个生命周期内有效（controller的队列、时间轮持有node的指针）；
+// map中只保存node的下标（uint32），map不包含指针，GC不需要扫描，同时也不需要为每一个node单独分配内存。
+// 删除的node放入空闲列表重新使用；纳入淘汰管理的node在controller放弃管理（Node.Abandon()）之前放入脏列表，由reclaim()回收，
+// 因此controller持有的node不会被重新使用。无锁读取持有的node可能被重新使用，读取时通过代数（Node.Gen()）判断。
+// nodePool不是并发安全的，由Storage加锁。
+type nodePool struct {
+	chunks [][]internal.Node
+	// 已经分配的node个数
+	count uint32
+	// 可以重新使用的node
+	free []uint32
+	// 已经删除、controller仍在管理的node
+	dirty []uint32
+}
+
+// node 下标对应的node
+func (p *nodePool) node(index uint32) *internal.Node {
+	chunk, offset := locate(index)
+	return &p.chunks[chunk][offset]
+}
+
+// locate 下标对应的块和块内的偏移
+func locate(index uint32) (chunk, offset uint32) {
+	if index >= nodeChunkSize {
+		return nodeSmallChunks - 1 + index>>nodeChunkBits, index & nodeChunkMask
+	}
+	if index < nodeChunkMinSize {
+		return 0, index
+	}
+	l := uint32(bits.Len32(index))
+	return l - nodeChunkMinBits, index - 1<<(l-1)
+}
+
+// alloc 分配一个node，优先使用空闲列表
+func (p *nodePool) alloc() (n *internal.Node) {
+	if last := len(p.free) - 1; last >= 0 {
+		n = p.node(p.free[last])
+		p.free = p.free[:last]
+		return n
+	}
+
+	if _, offset := locate(p.count); offset == 0 {
+		size := p.count
+		switch {
+		case size < nodeChunkMinSize:
+			size = nodeChunkMinSize
+		case size > nodeChunkSize:
+			size = nodeChunkSize
+		}
+		p.chunks = append(p.chunks, make([]internal.Node, size))
+	}
+	n = p.node(p.count)
+	n.Index = p.count
+	p.count++
+	return n
+}
+
+// release 回收node：不纳入淘汰管理的node直接放入空闲列表，否则放入脏列表
+func (p *nodePool) release(n *internal.Node) {
+	if n.IsDirect() {
+		p.free = append(p.free, n.Index)
+	} else {
+		p.dirty = append(p.dirty, n.Index)
+	}
+}
+
+// reclaim 将controller已经放弃管理的脏node放入空闲列表，返回回收的个数
+func (p *nodePool) reclaim() (count int) {
+	dirty := p.dirty[:0]
+	for _, index := range p.dirty {
+		if p.node(index).IsAbandoned() {
+			p.free = append(p.free, index)
+			count++
+		} else {
+			dirty = append(dirty, index)
+		}
+	}
+	p.dirty = dirty
+	return count
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"fmt"
+	"objectCache/internal"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestNodePool_Total(t *testing.T) {
+
+	var p nodePool
+
+	// 对象很少时只分配小块
+	p.alloc()
+	if len(p.chunks) != 1 || len(p.chunks[0]) != nodeChunkMinSize {
+		t.Error("失败0")
+	}
+	p = nodePool{}
+
+	// 按块分配，node的指针不会变化
+	nodes := make(map[uint32]interface{})
+	first := p.alloc()
+	for i := 1; i < nodeChunkSize*3; i++ {
+		n := p.alloc()
+		if n.Index != uint32(i) || p.node(n.Index) != n {
+			t.Fatal("失败1")
+		}
+		nodes[n.Index] = n
+	}
+	if len(p.chunks) != nodeSmallChunks+2 || p.node(0) != first || p.node(nodeChunkSize-1) != nodes[nodeChunkSize-1] {
+		t.Error("失败2")
+	}
+
+	// 不纳入淘汰管理的node直接重新使用
+	n := p.node(10)
+	n.SetDirect(true)
+	p.release(n)
+	if p.alloc() != n {
+		t.Error("失败3")
+	}
+
+	// 纳入淘汰管理的node在controller放弃管理后重新使用
+	n = p.node(20)
+	n.Hash = 20
+	p.release(n)
+	if p.reclaim() != 0 || p.alloc() == n {
+		t.Error("失败4")
+	}
+	n.Abandon()
+	if p.reclaim() != 1 || p.alloc() != n || len(p.dirty) != 0 {
+		t.Error("失败5")
+	}
+}
+
+// BenchmarkNodePool_GC 缓存大量对象时一次完整GC的耗时（主要是标记时间）和STW暂停时间，
+// 对比原来的map[uint64]*internal.Node（每个node单独分配）与现在的分段存储。
+// 默认只测试1千万个对象，设置环境变量OBJECTCACHE_BENCH_100M=1后同时测试1亿个对象（需要约10GB内存），如：
+// OBJECTCACHE_BENCH_100M=1 go test -run none -bench NodePool_GC -benchtime 5x ./internal/storage
+func BenchmarkNodePool_GC(b *testing.B) {
+	counts := []int{1e7}
+	if os.Getenv("OBJECTCACHE_BENCH_100M") != "" {
+		counts = append(counts, 1e8)
+	}
+
+	for _, count := range counts {
+		b.Run(fmt.Sprintf("pointerMap-%d", count), func(b *testing.B) {
+			m := make(map[uint64]*internal.Node, count)
+			for i := 0; i < count; i++ {
+				hash := uint64(i) + 1
+				m[hash] = &internal.Node{Hash: hash, Obj: true}
+			}
+			benchmarkGC(b)
+			runtime.KeepAlive(m)
+		})
+
+		b.Run(fmt.Sprintf("segments-%d", count), func(b *testing.B) {
+			s := NewSegments(DefaultSegmentCount(), internal.NodeUnitRestTime)
+			for i := 0; i < count; i++ {
+				hash := internal.HashFunc([]byte(fmt.Sprint(i)))
+				s.Of(hash).Set(true, hash, 0, 0)
+			}
+			benchmarkGC(b)
+			runtime.KeepAlive(s)
+		})
+	}
+}
+
+// benchmarkGC 每次执行一次完整的GC，记录平均的GC耗时和STW暂停时间
+func benchmarkGC(b *testing.B) {
+	runtime.GC()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	var total time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		runtime.GC()
+		total += time.Since(start)
+	}
+	b.StopTimer()
+
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(total.Nanoseconds())/float64(b.N), "gc-ns/op")
+	b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(after.NumGC-before.NumGC), "pause-ns/gc")
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"objectCache/internal"
+	"sync/atomic"
+	"unsafe"
+)
+
+// 读索引的最小容量
+const minReadTableSize = 16
+
+// readEntry 读索引中保存的对象，创建后不再修改。更新对象时创建新的readEntry替换
+type readEntry struct {
+	node *internal.Node
+	// 存储时node的代数，node被删除或重新使用后不再相同
+	gen    uint32
+	obj    interface{}
+	expire uint32
+}
+
+// recordEntry 记录读索引中的对象被访问一次，node已经被删除或重新使用则忽略
+func (s *Storage) recordEntry(e *readEntry) {
+	if e.node.Gen() == e.gen {
+		s.recordRead(e.node)
+	}
+}
+
+// readSlot 读索引的槽位：hash为0表示空槽位；entry为nil并且hash不为0表示对象已经被删除（墓碑）
+type readSlot struct {
+	hash  uint64
+	entry unsafe.Pointer
+}
+
+// readTable 开放寻址（线性探测）的哈希表，容量为2的幂
+type readTable struct {
+	slots []readSlot
+	mask  uint64
+	// 已经使用的槽位个数（包括墓碑）、有效的对象个数，只有写入者访问
+	used int
+	live int
+}
+
+// readIndex 无锁读取的索引：读取不加锁，只通过原子操作访问槽位；写入由Storage加锁串行执行。
+// 槽位的hash一旦写入不再修改（删除只清空entry），使用的槽位超过3/4时创建新的表并原子替换，墓碑在此时清除。
+// hash为0的对象单独保存在zero中。
+type readIndex struct {
+	table unsafe.Pointer
+	zero  unsafe.Pointer
+}
+
+func newReadIndex(size int) (x *readIndex) {
+	x = &readIndex{}
+	atomic.StorePointer(&x.table, unsafe.Pointer(newReadTable(size)))
+	return x
+}
+
+// newReadTable 创建可以容纳size个对象的表
+func newReadTable(size int) (t *readTable) {
+	capacity := minReadTableSize
+	for capacity*3/4 <= size*2 {
+		capacity <<= 1
+	}
+	return &readTable{slots: make([]readSlot, capacity), mask: uint64(capacity - 1)}
+}
+
+// load 无锁读取
+func (x *readIndex) load(hash uint64) (e *readEntry) {
+	if hash == 0 {
+		return (*readEntry)(atomic.LoadPointer(&x.zero))
+	}
+
+	t := (*readTable)(atomic.LoadPointer(&x.table))
+	for i := hash & t.mask; ; i = (i + 1) & t.mask {
+		h := atomic.LoadUint64(&t.slots[i].hash)
+		if h == hash {
+			return (*readEntry)(atomic.LoadPointer(&t.slots[i].entry))
+		}
+		if h == 0 {
+			return nil
+		}
+	}
+}
+
+// store 写入或者替换对象，调用者加锁
+func (x *readIndex) store(hash uint64, e *readEntry) {
+	if hash == 0 {
+		atomic.StorePointer(&x.zero, unsafe.Pointer(e))
+		return
+	}
+
+	t := (*readTable)(atomic.LoadPointer(&x.table))
+	if (t.used+1)*4 > len(t.slots)*3 {
+		t = x.grow(t)
+	}
+
+	for i := hash & t.mask; ; i = (i + 1) & t.mask {
+		h := t.slots[i].hash
+		if h == hash {
+			if atomic.LoadPointer(&t.slots[i].entry) == nil {
+				t.live++
+			}
+			atomic.StorePointer(&t.slots[i].entry, unsafe.Pointer(e))
+			return
+		}
+		if h == 0 {
+			// 先写入entry再写入hash，读取时看到hash就能看到entry
+			atomic.StorePointer(&t.slots[i].entry, unsafe.Pointer(e))
+			atomic.StoreUint64(&t.slots[i].hash, hash)
+			t.used++
+			t.live++
+			return
+		}
+	}
+}
+
+// remove 删除对象，调用者加锁
+func (x *readIndex) remove(hash uint64) {
+	if hash == 0 {
+		atomic.StorePointer(&x.zero, nil)
+		return
+	}
+
+	t := (*readTable)(atomic.LoadPointer(&x.table))
+	for i := hash & t.mask; ; i = (i + 1) & t.mask {
+		h := t.slots[i].hash
+		if h == hash {
+			if atomic.LoadPointer(&t.slots[i].entry) != nil {
+				atomic.StorePointer(&t.slots[i].entry, nil)
+				t.live--
+			}
+			return
+		}
+		if h == 0 {
+			return
+		}
+	}
+}
+
+// grow 根据有效的对象个数创建新的表（清除墓碑）并替换，调用者加锁。
+// 正在读取原来的表的goroutine仍然可以完成读取
+func (x *readIndex) grow(old *readTable) (t *readTable) {
+	t = newReadTable(old.live)
+	for k := range old.slots {
+		e := atomic.LoadPointer(&old.slots[k].entry)
+		if e == nil {
+			continue
+		}
+		hash := old.slots[k].hash
+		for i := hash & t.mask; ; i = (i + 1) & t.mask {
+			if t.slots[i].hash == 0 {
+				t.slots[i].hash = hash
+				t.slots[i].entry = e
+				break
+			}
+		}
+		t.used++
+		t.live++
+	}
+	atomic.StorePointer(&x.table, unsafe.Pointer(t))
+	return t
+}
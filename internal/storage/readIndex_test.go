@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"objectCache/internal"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadIndex_Total(t *testing.T) {
+
+	x := newReadIndex(0)
+
+	// 包括hash为0的对象
+	for i := 0; i < 1000; i++ {
+		x.store(uint64(i), &readEntry{obj: i})
+	}
+	for i := 0; i < 1000; i++ {
+		if e := x.load(uint64(i)); e == nil || e.obj != i {
+			t.Error("失败1")
+		}
+	}
+	if x.load(1000) != nil {
+		t.Error("失败2")
+	}
+
+	// 替换
+	x.store(10, &readEntry{obj: "new"})
+	if x.load(10).obj != "new" {
+		t.Error("失败3")
+	}
+
+	// 删除后重新写入
+	for i := 0; i < 500; i++ {
+		x.remove(uint64(i))
+	}
+	for i := 0; i < 500; i++ {
+		if x.load(uint64(i)) != nil {
+			t.Error("失败4")
+		}
+	}
+	x.store(5, &readEntry{obj: 5})
+	if x.load(5) == nil || x.load(6) != nil || x.load(600).obj != 600 {
+		t.Error("失败5")
+	}
+
+	// 反复写入删除，扩容时清除墓碑，表不会无限增长
+	for i := 0; i < 100000; i++ {
+		x.store(uint64(i)+1e6, &readEntry{})
+		x.remove(uint64(i) + 1e6)
+	}
+	table := (*readTable)(x.table)
+	if table.live != 501 || len(table.slots) > 4096 {
+		t.Error("失败6", table.live, len(table.slots))
+	}
+}
+
+func TestReadIndex_Storage(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+	s.Set(data{id: 1}, 1, 0, 0)
+	s.EnableLockFreeReads()
+	s.Set(data{id: 2}, 2, 10, 0)
+
+	if n, ok := s.Get(1); !ok || n.Hash != 1 {
+		t.Error("失败1")
+	}
+	if _, obj, expire, ok := s.GetValue(2); !ok || obj.(data).id != 2 || expire == 0 {
+		t.Error("失败2")
+	}
+
+	s.Set(data{id: 3}, 2, 0, 0)
+	if _, obj, _, _ := s.GetValue(2); obj.(data).id != 3 {
+		t.Error("失败3")
+	}
+
+	s.Del(1)
+	if _, ok := s.Get(1); ok || s.Has(1) || !s.Has(2) {
+		t.Error("失败4")
+	}
+
+	// node被删除、重新使用后，之前的readEntry不再增加其访问次数
+	n, _ := s.SetDirect(data{id: 4}, 4, 0, 0)
+	old := s.reads.load(4)
+	s.Del(4)
+	if reused, _ := s.SetDirect(data{id: 5}, 5, 0, 0); reused != n || old.gen == n.Gen() {
+		t.Fatal("失败5")
+	}
+	atomic.StoreUint32(&n.LastReadTime, 0)
+	s.recordEntry(old)
+	if n.GetCurrentCount() != 0 {
+		t.Error("失败6")
+	}
+}
+
+// TestReadIndex_Stress 并发读写的压力测试，需要使用 go test -race 运行。读取到的对象必须与hash一致
+func TestReadIndex_Stress(t *testing.T) {
+
+	const keys = 4096
+	s := NewStorage(internal.NodeUnitRestTime)
+	s.EnableLockFreeReads()
+
+	var stop int32
+	var wg sync.WaitGroup
+
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; atomic.LoadInt32(&stop) == 0; i++ {
+				hash := uint64((i*7+w)%keys) + 1
+				if i%3 == 0 {
+					s.Del(hash)
+				} else {
+					s.Set(hash, hash, i%5, 0)
+				}
+			}
+		}(w)
+	}
+
+	var reads, wrong int64
+	for r := 0; r < runtime.GOMAXPROCS(0)+2; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; atomic.LoadInt32(&stop) == 0; i++ {
+				hash := uint64((i*13+r)%keys) + 1
+				if _, obj, _, ok := s.GetValue(hash); ok {
+					if obj.(uint64) != hash {
+						atomic.AddInt64(&wrong, 1)
+					}
+					atomic.AddInt64(&reads, 1)
+				}
+				s.Has(hash)
+			}
+		}(r)
+	}
+
+	time.Sleep(time.Second)
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+
+	if wrong != 0 || reads == 0 {
+		t.Error("失败1", wrong, reads)
+	}
+}
+
+// TestReadIndex_PeekStress Peek与存储、删除并发的压力测试，需要使用 go test -race 运行。
+// 不纳入淘汰管理的node删除后直接重新使用，Peek读取淘汰状态时node可能正在被重新初始化
+func TestReadIndex_PeekStress(t *testing.T) {
+
+	const keys = 64
+	s := NewStorage(internal.NodeUnitRestTime)
+	s.EnableLockFreeReads()
+
+	var stop int32
+	var wg sync.WaitGroup
+
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; atomic.LoadInt32(&stop) == 0; i++ {
+				hash := uint64((i*7+w)%keys) + 1
+				if i%2 == 0 {
+					s.Del(hash)
+				} else {
+					s.SetDirect(hash, hash, i%5, 0)
+				}
+			}
+		}(w)
+	}
+
+	var reads, wrong int64
+	for r := 0; r < runtime.GOMAXPROCS(0)+2; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; atomic.LoadInt32(&stop) == 0; i++ {
+				hash := uint64((i*13+r)%keys) + 1
+				if obj, meta, ok := s.Peek(hash); ok {
+					if obj.(uint64) != hash || !meta.Direct {
+						atomic.AddInt64(&wrong, 1)
+					}
+					atomic.AddInt64(&reads, 1)
+				}
+			}
+		}(r)
+	}
+
+	time.Sleep(time.Second)
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+
+	if wrong != 0 || reads == 0 {
+		t.Error("失败1", wrong, reads)
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"math/bits"
+	"runtime"
+	"sync/atomic"
+)
+
+const (
+	// 分段个数的最大值
+	MaxSegmentCount = 1 << 16
+	// 默认分段个数的最小值
+	minDefaultSegmentCount = 256
+)
+
+// Segments 一组Storage（分段），分段个数为2的幂，根据hash的高位选择分段。
+// storage.Storage中map的桶由hash的低位决定，使用高位选择分段避免同一分段中的hash低位相同
+type Segments struct {
+	List  []*Storage
+	shift uint
+
+	// 序列化存储模式下所有分段有效数据的字节数
+	bytes int64
+}
+
+// NewSegments 创建count个分段，count需要为2的幂（见ValidSegmentCount）
+func NewSegments(count int, unitRestTime uint32) (s *Segments) {
+	s = &Segments{
+		List:  make([]*Storage, count),
+		shift: uint(64 - bits.TrailingZeros(uint(count))),
+	}
+	for i := range s.List {
+		s.List[i] = NewStorage(unitRestTime)
+		s.List[i].liveBytes = &s.bytes
+	}
+	return s
+}
+
+// Index hash所在分段的下标
+func (s *Segments) Index(hash uint64) uint64 {
+	// 只有一个分段时shift为64，结果为0
+	return hash >> s.shift
+}
+
+// Of hash所在的分段
+func (s *Segments) Of(hash uint64) *Storage {
+	return s.List[hash>>s.shift]
+}
+
+// Bytes 序列化存储模式下所有分段有效数据（没有被删除、覆盖的对象）的字节数，不包括字节区中失效、空闲的部分
+func (s *Segments) Bytes() int64 {
+	return atomic.LoadInt64(&s.bytes)
+}
+
+// DefaultSegmentCount 默认的分段个数：GOMAXPROCS的16倍向上取2的幂，最小为256
+func DefaultSegmentCount() (count int) {
+	count = minDefaultSegmentCount
+	for count < runtime.GOMAXPROCS(0)*16 && count < MaxSegmentCount {
+		count <<= 1
+	}
+	return count
+}
+
+// ValidSegmentCount 分段个数是否有效：[1, MaxSegmentCount]之间的2的幂
+func ValidSegmentCount(count int) bool {
+	return count > 0 && count <= MaxSegmentCount && count&(count-1) == 0
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand"
+	"objectCache/internal"
+	"runtime"
+	"testing"
+)
+
+func TestSegments_Total(t *testing.T) {
+
+	if !ValidSegmentCount(1) || !ValidSegmentCount(MaxSegmentCount) || ValidSegmentCount(0) ||
+		ValidSegmentCount(100) || ValidSegmentCount(MaxSegmentCount*2) {
+		t.Error("失败1")
+	}
+
+	count := DefaultSegmentCount()
+	if !ValidSegmentCount(count) || count < 256 || count < runtime.GOMAXPROCS(0)*16 && count < MaxSegmentCount {
+		t.Error("失败2", count)
+	}
+
+	// 只有一个分段
+	s := NewSegments(1, internal.NodeUnitRestTime)
+	if s.Index(^uint64(0)) != 0 || s.Of(12345) != s.List[0] {
+		t.Error("失败3")
+	}
+
+	// 使用高位选择分段
+	s = NewSegments(16, internal.NodeUnitRestTime)
+	if s.Index(0xf<<60) != 15 || s.Index(0xfff) != 0 {
+		t.Error("失败4")
+	}
+	used := make(map[uint64]bool)
+	for i := 0; i < 1000; i++ {
+		used[s.Index(internal.HashFunc([]byte(fmt.Sprint(i))))] = true
+	}
+	if len(used) != 16 {
+		t.Error("失败5")
+	}
+
+	// 序列化存储模式下所有分段共享有效数据的字节数
+	for _, segment := range s.List {
+		segment.EnableArena(0, false)
+	}
+	s.List[0].Set(make([]byte, 100), 1, 0, 0)
+	s.List[15].Set(make([]byte, 50), 0xf<<60, 0, 0)
+	s.List[0].Set(make([]byte, 30), 1, 0, 0)
+	if s.Bytes() != 80 {
+		t.Error("失败6", s.Bytes())
+	}
+	s.List[15].Del(0xf << 60)
+	if s.Bytes() != 30 {
+		t.Error("失败7", s.Bytes())
+	}
+}
+
+// BenchmarkSegments_Contention 不同分段个数以及无锁读取时并发读写（读写比例为9:1）的性能，CPU核数越多差异越明显，
+// 如：go test -bench Contention -cpu 1,8,64 ./internal/storage
+func BenchmarkSegments_Contention(b *testing.B) {
+	const keys = 1 << 16
+	hashes := make([]uint64, keys)
+	for i := range hashes {
+		hashes[i] = internal.HashFunc([]byte(fmt.Sprint(i)))
+	}
+
+	for _, bc := range []struct {
+		count    int
+		lockFree bool
+	}{{16, false}, {256, false}, {4096, false}, {256, true}} {
+		name := fmt.Sprintf("shards-%d", bc.count)
+		if bc.lockFree {
+			name += "-lockfree"
+		}
+		b.Run(name, func(b *testing.B) {
+			s := NewSegments(bc.count, internal.NodeUnitRestTime)
+			if bc.lockFree {
+				for _, segment := range s.List {
+					segment.EnableLockFreeReads()
+				}
+			}
+			for _, hash := range hashes {
+				s.Of(hash).Set(hash, hash, 0, 0)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for i := 0; pb.Next(); i++ {
+					hash := hashes[r.Intn(keys)]
+					if i%10 == 0 {
+						s.Of(hash).Set(hash, hash, 0, 0)
+					} else {
+						s.Of(hash).Get(hash)
+					}
+				}
+			})
+		})
+	}
+}
@@ -4,63 +4,585 @@ import (
 	"math"
 	"objectCache/internal"
 	"sync"
-	"time"
-)
-
-const (
-	MaxSegmentSize = 256
+	"sync/atomic"
 )
 
 // Storage存储对象的并发单元
-// 持有一个读写锁和一个map，internal.Node直接存储与map中，读写锁就锁定这个map。
+// 持有一个读写锁和一个map，map中保存node在nodePool中的下标，读写锁就锁定这个map。
+// 启用无锁读取后，写入时同时更新读索引（readIndex），Get()等读取操作不加锁。
 type Storage struct {
 	sync.RWMutex
-	NodeMap map[uint64]*internal.Node
+	// hash -> node的下标，不包含指针
+	index map[uint64]uint32
+	pool  nodePool
+
+	// 被访问的单位时间（单位为秒），见internal.EvictionConfig.NodeUnitRestTime
+	UnitRestTime uint32
+
+	// 设置了过期时间的对象的过期索引，在第一次存入此类对象时创建
+	wheel *internal.TimingWheel
+
+	// 序列化存储模式的字节区，为nil则对象直接保存在Node.Obj中
+	arena *arena
+
+	// 无锁读取的索引，为nil则读取时加读锁
+	reads *readIndex
+
+	// 记录纳入淘汰管理的对象被访问的缓冲区（由controller读取），为nil则读取时直接更新node的访问次数
+	access *internal.ReadBuffer
+
+	// 对象被淘汰、过期删除后的回调，为nil则不回调
+	onRemove RemoveHook
+
+	// 过期时间的随机抖动（所有分段共享），为nil则不抖动
+	jitter *internal.TTLJitter
+
+	// 滑动过期：读取对象时把过期时间推迟到当前时间+Node.TTL
+	sliding bool
+
+	// 所有分段共享的有效数据字节数（序列化存储模式），由Segments设置，为nil则不统计
+	liveBytes *int64
+}
+
+// RemoveReason 对象被删除的原因，见RemoveHook
+type RemoveReason int
+
+const (
+	// RemoveEvicted 被controller淘汰
+	RemoveEvicted RemoveReason = iota + 1
+	// RemoveExpired 过期删除
+	RemoveExpired
+)
+
+// RemoveHook 对象被淘汰、过期删除后调用（已经释放锁，可以调用Storage的方法），value为删除前存储的对象，序列化存储模式下为数据的副本
+type RemoveHook func(hash uint64, value interface{}, reason RemoveReason)
+
+// arenaValue 序列化存储模式下Node.Obj的值。controller以Obj为nil判断对象被删除，所以需要一个非nil的值（不占用内存）
+var arenaValue interface{} = struct{}{}
+
+func NewStorage(unitRestTime uint32) (s *Storage) {
+	return &Storage{
+		index:        make(map[uint64]uint32),
+		UnitRestTime: unitRestTime,
+	}
+}
+
+// EnableArena 启用序列化存储模式，之后存储的对象必须为[]byte，保存在字节区中。size为字节区的初始大小，需要在存储对象前调用。
+// offHeap为true则字节区使用mmap分配在Go堆外（OffHeapSupported为false的平台使用Go堆）
+func (s *Storage) EnableArena(size uint32, offHeap bool) {
+	s.Lock()
+	s.arena = newArena(size, offHeap && OffHeapSupported)
+	s.Unlock()
+}
+
+// EnableLockFreeReads 启用无锁读取，读多写少时减少读写锁的竞争。不能与序列化存储模式同时使用（整理字节区时需要加锁）
+func (s *Storage) EnableLockFreeReads() {
+	s.Lock()
+	s.reads = newReadIndex(len(s.index))
+	for hash, index := range s.index {
+		n := s.pool.node(index)
+		s.reads.store(hash, &readEntry{node: n, gen: n.Gen(), obj: n.Obj, expire: n.Expire})
+	}
+	s.Unlock()
+}
+
+// SetReadBuffer 设置记录对象被访问的缓冲区，需要在存储对象前调用
+func (s *Storage) SetReadBuffer(b *internal.ReadBuffer) {
+	s.Lock()
+	s.access = b
+	s.Unlock()
+}
+
+// SetRemoveHook 设置对象被淘汰、过期删除后的回调，需要在存储对象前调用
+func (s *Storage) SetRemoveHook(fn RemoveHook) {
+	s.Lock()
+	s.onRemove = fn
+	s.Unlock()
+}
+
+// SetTTLJitter 设置过期时间的随机抖动，需要在存储对象前调用
+func (s *Storage) SetTTLJitter(j *internal.TTLJitter) {
+	s.Lock()
+	s.jitter = j
+	s.Unlock()
+}
+
+// EnableSlidingExpiration 启用滑动过期，不能与无锁读取同时使用（读取时需要持有读锁修改node）
+func (s *Storage) EnableSlidingExpiration() {
+	s.Lock()
+	s.sliding = true
+	s.Unlock()
+}
+
+// recordRead 记录node被访问一次：设置了缓冲区则写入缓冲区（不纳入淘汰管理的对象不记录），否则直接更新node的访问次数
+func (s *Storage) recordRead(n *internal.Node) {
+	if s.access == nil {
+		_ = n.IncrementReadCount(s.UnitRestTime)
+	} else if !n.IsDirect() {
+		s.access.Record(n)
+	}
+}
+
+// Set 存储纳入淘汰管理的对象，n为存储对象的node，ok为是否是新增的对象；topicID只对新增的对象有效。
+// 序列化存储模式下字节区整理后仍超过最大大小则不存储（原有的对象不受影响），n为nil
+func (s *Storage) Set(obj interface{}, hash uint64, expire int, topicID uint32) (n *internal.Node, ok bool) {
+	s.Lock()
+	n, ok = s.set(obj, hash, expire, topicID, false)
+	s.Unlock()
+	return n, ok
+}
+
+// Update 与Set相同，只更新已经存在的对象，ok为对象是否存在。对象不存在，或者字节区超过最大大小时不存储，n为nil
+func (s *Storage) Update(obj interface{}, hash uint64, expire int) (n *internal.Node, ok bool) {
+	s.Lock()
+	if _, ok = s.index[hash]; ok {
+		n, _ = s.set(obj, hash, expire, 0, false)
+	}
+	s.Unlock()
+	return n, ok
 }
 
-func (s *Storage) Set(obj interface{}, hash uint64, expire int, n *internal.Node) (ok bool) {
+// SetDirect 与Set相同，用于不纳入淘汰管理的对象
+func (s *Storage) SetDirect(obj interface{}, hash uint64, expire int, topicID uint32) (n *internal.Node, ok bool) {
 	s.Lock()
-	var now = time.Now()
-	if _, ok = s.NodeMap[hash]; !ok {
+	n, ok = s.set(obj, hash, expire, topicID, true)
+	s.Unlock()
+	return n, ok
+}
+
+// set 存储对象，调用者加锁。ok为是否是新增的对象；topicID、direct只对新增的对象有效。
+// 设置了过期时间的对象加入时间轮，由Sweep()删除过期的对象。设置了随机抖动则按对象的topic缩短过期时间。
+// 字节区没有足够的空间时不修改任何状态，返回的node为nil
+func (s *Storage) set(obj interface{}, hash uint64, expire int, topicID uint32, direct bool) (node *internal.Node, ok bool) {
+	var now = internal.Now()
+	index, ok := s.index[hash]
+	if s.arena != nil {
+		var freed uint32
+		if ok {
+			freed = s.pool.node(index).Length
+		}
+		if !s.arena.fits(uint32(len(obj.([]byte))), freed) {
+			return nil, false
+		}
+	}
+	if ok {
+		node = s.pool.node(index)
+		node.Obj = obj
+		s.recordRead(node)
+	} else {
+		n := s.pool.alloc()
 		n.Hash = hash
 		n.Obj = obj
-		n.RestBeginTime = 0
-		n.TotalTime = 0
+		n.Length = 0
+		// 无锁读取（Peek）可能同时通过Node.Meta()读取重新使用的node，Meta()读取的字段需要原子写入
+		atomic.StoreUint32(&n.RestBeginTime, 0)
+		atomic.StoreUint32(&n.TotalTime, 0)
+		atomic.StoreUint32(&n.TotalCount, 0)
 		n.InitReadCount()
-		n.LastReadTime = uint32(now.Unix()) - internal.NodeUnitRestTime
-		s.NodeMap[n.Hash] = n
-	} else {
-		s.NodeMap[hash].Obj = n.Obj
-		_ = s.NodeMap[hash].IncrementReadCount()
+		n.WheelExpire = 0
+		n.ResetFlags()
+		n.SetDirect(direct)
+		atomic.StoreUint32(&n.LastReadTime, now-s.UnitRestTime)
+		n.SetExpire(0)
+		n.TopicID = topicID
+		s.index[hash] = n.Index
+		node = n
+	}
+
+	if s.arena != nil {
+		s.storeBytes(node, obj.([]byte))
 	}
 
 	if expire > 0 {
-		s.NodeMap[hash].Expire = uint32(now.Add(time.Second * time.Duration(expire)).Unix())
+		ttl := uint32(expire)
+		node.TTL = ttl
+		if s.jitter != nil {
+			// 同一个对象每秒重新存储时抖动不同
+			ttl = s.jitter.Apply(node.TopicID, ttl, node.Hash^uint64(now))
+		}
+		node.SetExpire(now + ttl)
 	} else {
-		s.NodeMap[hash].Expire = math.MaxUint32 // 2106-02-07 14:28:15 +0800 CST
+		node.TTL = 0
+		node.SetExpire(math.MaxUint32) // 2106-02-07 14:28:15 +0800 CST
 	}
+	s.schedule(node, now)
 
-	s.Unlock()
-	return !ok
+	if s.reads != nil {
+		s.reads.store(hash, &readEntry{node: node, gen: node.Gen(), obj: obj, expire: node.Expire})
+	}
+
+	return node, !ok
+}
+
+// schedule 过期时间被修改后调用，设置了过期时间的node加入时间轮（过期时间被推迟则不需要重新加入），调用者加锁
+func (s *Storage) schedule(n *internal.Node, now uint32) {
+	if n.Expire == math.MaxUint32 {
+		return
+	}
+	if s.wheel == nil {
+		s.wheel = internal.NewTimingWheel(now)
+	}
+	s.wheel.Schedule(n)
+}
+
+// slide 滑动过期：读取对象时把过期时间推迟到当前时间+Node.TTL，已经过期的对象不受影响。
+// 调用者加读锁，多个读取方同时推迟时只保留较晚的时间；时间轮中的项到期后由Sweep()按新的过期时间重新加入
+func (s *Storage) slide(n *internal.Node) {
+	if !s.sliding || n.TTL == 0 {
+		return
+	}
+
+	now := internal.Now()
+	for {
+		old := n.GetExpire()
+		if now > old || now+n.TTL <= old {
+			return
+		}
+		if atomic.CompareAndSwapUint32(&n.Expire, old, now+n.TTL) {
+			return
+		}
+	}
 }
 
 func (s *Storage) Get(hash uint64) (n *internal.Node, ok bool) {
+	if s.reads != nil {
+		if e := s.reads.load(hash); e != nil {
+			s.recordEntry(e)
+			return e.node, true
+		}
+		return nil, false
+	}
+
+	s.RLock()
+	index, ok := s.index[hash]
+	if ok {
+		n = s.pool.node(index)
+		s.recordRead(n)
+		s.slide(n)
+	}
+	s.RUnlock()
+	return
+}
+
+// GetValue 与Get相同，同时返回存储的对象和过期时间，序列化存储模式下返回数据的副本（[]byte）
+func (s *Storage) GetValue(hash uint64) (n *internal.Node, obj interface{}, expire uint32, ok bool) {
+	if s.reads != nil {
+		if e := s.reads.load(hash); e != nil {
+			s.recordEntry(e)
+			return e.node, e.obj, e.expire, true
+		}
+		return nil, nil, 0, false
+	}
+
+	s.RLock()
+	index, ok := s.index[hash]
+	if ok {
+		n = s.pool.node(index)
+		s.recordRead(n)
+		s.slide(n)
+		expire = n.GetExpire()
+		obj = s.value(n)
+	}
+	s.RUnlock()
+	return
+}
+
+// value node存储的对象，序列化存储模式下返回数据的副本，调用者加锁
+func (s *Storage) value(n *internal.Node) (obj interface{}) {
+	if s.arena != nil {
+		return append([]byte(nil), s.arena.bytes(n.Offset, n.Length)...)
+	}
+	return n.Obj
+}
+
+// Peek 与GetValue相同，同时返回node的淘汰状态，不计入访问次数，也不推迟滑动过期的时间
+func (s *Storage) Peek(hash uint64) (obj interface{}, meta internal.NodeMeta, ok bool) {
+	if s.reads != nil {
+		e := s.reads.load(hash)
+		if e == nil {
+			return nil, meta, false
+		}
+		meta = e.node.Meta()
+		// 读取期间node被删除或重新使用
+		if e.node.Gen() != e.gen {
+			return nil, internal.NodeMeta{}, false
+		}
+		meta.Expire = e.expire
+		return e.obj, meta, true
+	}
+
+	s.RLock()
+	index, ok := s.index[hash]
+	if ok {
+		n := s.pool.node(index)
+		obj = s.value(n)
+		meta = n.Meta()
+	}
+	s.RUnlock()
+	return
+}
+
+// Has 判断对象是否存在，不计入访问次数
+func (s *Storage) Has(hash uint64) (ok bool) {
+	if s.reads != nil {
+		return s.reads.load(hash) != nil
+	}
+
+	s.RLock()
+	_, ok = s.index[hash]
+	s.RUnlock()
+	return ok
+}
+
+// ExpireOf 获取对象的过期时间（Unix time，单位为秒，不过期为math.MaxUint32），不计入访问次数，也不推迟滑动过期的时间
+func (s *Storage) ExpireOf(hash uint64) (expire uint32, ok bool) {
+	if s.reads != nil {
+		if e := s.reads.load(hash); e != nil {
+			return e.expire, true
+		}
+		return 0, false
+	}
+
 	s.RLock()
-	n, ok = s.NodeMap[hash]
+	index, ok := s.index[hash]
 	if ok {
-		_ = n.IncrementReadCount()
+		expire = s.pool.node(index).GetExpire()
 	}
 	s.RUnlock()
 	return
 }
 
+// Expire 修改对象的过期时间（单位为秒，不抖动，滑动过期时作为新的TTL），expire小于等于0则不过期。
+// 对象不存在或者已经过期（还没有被删除）返回false
+func (s *Storage) Expire(hash uint64, expire int) (ok bool) {
+	return s.updateExpire(hash, expire, false)
+}
+
+// Touch 与Expire相同，只在新的过期时间晚于原来的过期时间时修改（延长），不过期的对象不受影响。
+// expire小于等于0时不修改（不会取消对象的过期时间）
+func (s *Storage) Touch(hash uint64, expire int) (ok bool) {
+	return s.updateExpire(hash, expire, true)
+}
+
+// updateExpire 修改对象的过期时间，extend为true则只延长
+func (s *Storage) updateExpire(hash uint64, expire int, extend bool) (ok bool) {
+	now := internal.Now()
+	ttl, at := uint32(0), uint32(math.MaxUint32)
+	if expire > 0 {
+		ttl, at = uint32(expire), now+uint32(expire)
+	}
+
+	s.Lock()
+	index, ok := s.index[hash]
+	if ok {
+		n := s.pool.node(index)
+		if n.Expire != math.MaxUint32 && now > n.Expire {
+			ok = false
+		} else if !extend || (ttl > 0 && at > n.Expire) {
+			n.TTL = ttl
+			n.SetExpire(at)
+			s.schedule(n, now)
+			if s.reads != nil {
+				s.reads.store(hash, &readEntry{node: n, gen: n.Gen(), obj: n.Obj, expire: at})
+			}
+		}
+	}
+	s.Unlock()
+	return ok
+}
+
+// Del 删除对象，返回的node已经被回收，只能用于读取删除前的状态（如IsDirect()）
 func (s *Storage) Del(hash uint64) (n *internal.Node, ok bool) {
 	s.Lock()
-	if n, ok = s.NodeMap[hash]; ok {
-		delete(s.NodeMap, hash)
-		// 释放存储对象，controller会对其进行检查，判断此对象是否被主动删除
-		n.Obj = nil
+	var index uint32
+	if index, ok = s.index[hash]; ok {
+		n = s.pool.node(index)
+		// 释放存储对象并标记为已删除，controller会对其进行检查，判断此对象是否被主动删除
+		s.remove(hash, n)
+	}
+	s.Unlock()
+	return
+}
+
+// DelNode 删除node存储的对象，node已经被删除（此时hash可能已经对应新的node）则返回false。
+// 用于controller淘汰、删除过期的对象，不会误删同一个hash重新存储的对象，删除后以reason调用RemoveHook
+func (s *Storage) DelNode(n *internal.Node, reason RemoveReason) (ok bool) {
+	var hash uint64
+	var value interface{}
+
+	s.Lock()
+	if index, exist := s.index[n.Hash]; exist && index == n.Index && !n.IsRemoved() {
+		hash = n.Hash
+		if s.onRemove != nil {
+			value = s.value(n)
+		}
+		s.remove(hash, n)
+		ok = true
+	}
+	fn := s.onRemove
+	s.Unlock()
+
+	if ok && fn != nil {
+		fn(hash, value, reason)
+	}
+	return ok
+}
+
+// Pin 设置对象是否固定，对象不存在返回false
+func (s *Storage) Pin(hash uint64, pinned bool) (ok bool) {
+	s.RLock()
+	index, ok := s.index[hash]
+	if ok {
+		s.pool.node(index).SetPinned(pinned)
+	}
+	s.RUnlock()
+	return ok
+}
+
+// removed 被删除的对象，释放锁后调用RemoveHook
+type removed struct {
+	hash  uint64
+	value interface{}
+}
+
+// Sweep 删除时间轮中到now为止过期的对象，对每一个删除的node调用fn（此时仍持有锁，node已经被回收，只能读取）。
+// 删除的node被标记为已删除，纳入淘汰管理的node仍在controller的队列中，controller通过directEliminate()识别并丢弃。
+// 过期时间被推迟的node按新的过期时间重新加入时间轮。释放锁后对删除的对象调用RemoveHook
+func (s *Storage) Sweep(now uint32, fn func(n *internal.Node)) {
+	var expired []removed
+
+	s.Lock()
+	if s.wheel != nil {
+		s.wheel.Advance(now, func(n *internal.Node, hash uint64, expire uint32) {
+			// node已经被删除、重新使用
+			if index, ok := s.index[hash]; !ok || index != n.Index {
+				return
+			}
+			// 过期时间被修改
+			if n.Expire > now {
+				s.wheel.Reschedule(n, expire)
+				return
+			}
+			if s.onRemove != nil {
+				expired = append(expired, removed{hash: hash, value: s.value(n)})
+			}
+			s.remove(hash, n)
+			fn(n)
+		})
+	}
+	hook := s.onRemove
+	s.Unlock()
+
+	for _, v := range expired {
+		hook(v.hash, v.value, RemoveExpired)
+	}
+}
+
+// WheelLen 时间轮中项的个数
+func (s *Storage) WheelLen() (count int) {
+	s.RLock()
+	if s.wheel != nil {
+		count = s.wheel.Len()
+	}
+	s.RUnlock()
+	return count
+}
+
+// DelFunc 删除所有match返回true的对象，对每一个删除的node调用fn（同Sweep），返回删除的个数
+func (s *Storage) DelFunc(match func(n *internal.Node) bool, fn func(n *internal.Node)) (count int) {
+	s.Lock()
+	for hash, index := range s.index {
+		n := s.pool.node(index)
+		if match(n) {
+			s.remove(hash, n)
+			fn(n)
+			count++
+		}
 	}
 	s.Unlock()
+	return count
+}
+
+// Reclaim 回收controller已经放弃管理的node，返回回收的个数
+func (s *Storage) Reclaim() (count int) {
+	s.Lock()
+	count = s.pool.reclaim()
+	s.Unlock()
+	return count
+}
+
+// Len 对象的个数
+func (s *Storage) Len() (count int) {
+	s.RLock()
+	count = len(s.index)
+	s.RUnlock()
+	return count
+}
+
+// remove 从map中删除对象，释放node存储的对象并回收node，调用者加锁
+func (s *Storage) remove(hash uint64, n *internal.Node) {
+	delete(s.index, hash)
+	if s.reads != nil {
+		s.reads.remove(hash)
+	}
+	n.Obj = nil
+	n.Remove()
+	if s.arena != nil {
+		s.arena.free(n.Length)
+		s.addLiveBytes(-int64(n.Length))
+		n.Length = 0
+		// 及时释放被删除对象占用的内存
+		if s.arena.shouldShrink() {
+			s.compact(0)
+		}
+	}
+	s.pool.release(n)
+}
+
+// storeBytes 将node的数据保存到字节区，调用者加锁。空间不足时先整理字节区，调用前已经由arena.fits()确认整理后的空间足够
+func (s *Storage) storeBytes(n *internal.Node, data []byte) {
+	// 更新的对象，原来的数据失效
+	s.arena.free(n.Length)
+	s.addLiveBytes(int64(len(data)) - int64(n.Length))
+	n.Length = 0
+	n.Obj = arenaValue
+
+	offset, ok := s.arena.alloc(data)
+	if !ok {
+		s.compact(uint32(len(data)))
+		offset, _ = s.arena.alloc(data)
+	}
+	n.Offset = offset
+	n.Length = uint32(len(data))
+}
+
+// addLiveBytes 更新所有分段共享的有效数据字节数
+func (s *Storage) addLiveBytes(delta int64) {
+	if s.liveBytes != nil && delta != 0 {
+		atomic.AddInt64(s.liveBytes, delta)
+	}
+}
+
+// compact 整理字节区：只复制有效的数据到新的字节区，need为整理后需要追加的字节数
+func (s *Storage) compact(need uint32) {
+	old := s.arena
+	s.arena = old.resize(old.newSize(need))
+	for _, index := range s.index {
+		n := s.pool.node(index)
+		if n.Length == 0 {
+			continue
+		}
+		n.Offset, _ = s.arena.alloc(old.bytes(n.Offset, n.Length))
+	}
+	old.release()
+}
+
+// ArenaStats 字节区的大小、已经使用的字节数、失效的字节数，没有启用序列化存储模式则都为0
+func (s *Storage) ArenaStats() (size, used, dead uint32) {
+	s.RLock()
+	if s.arena != nil {
+		size, used, dead = uint32(len(s.arena.buf)), s.arena.used, s.arena.dead
+	}
+	s.RUnlock()
 	return
 }
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"math"
 	"objectCache/internal"
+	"sync"
 	"testing"
 	"time"
 )
@@ -13,12 +15,11 @@ type data struct {
 
 func TestStorage_Total(t *testing.T) {
 
-	nc := internal.NewNodeCache(1000)
-	s := Storage{NodeMap: make(map[uint64]*internal.Node)}
+	s := NewStorage(internal.NodeUnitRestTime)
 
 	// set
 	for i := 0; i < 100; i++ {
-		if !s.Set(data{id: i, name: "aa"}, uint64(i), 0, nc.GetNode()) {
+		if _, ok := s.Set(data{id: i, name: "aa"}, uint64(i), 0, 0); !ok {
 			t.Error("失败1")
 		}
 	}
@@ -58,8 +59,17 @@ func TestStorage_Total(t *testing.T) {
 		t.Error("失败8")
 	}
 
-	nc.SaveDirtyNode(n)
-	n.Hash = 0
+	// controller放弃管理后回收
+	if s.Reclaim() != 0 {
+		t.Error("失败10")
+	}
+	n.Abandon()
+	if s.Reclaim() != 1 {
+		t.Error("失败11")
+	}
+	if reused, _ := s.Set(data{id: 200}, 200, 0, 0); reused != n {
+		t.Error("失败12")
+	}
 
 	n, ok = s.Del(10)
 	if ok {
@@ -67,3 +77,338 @@ func TestStorage_Total(t *testing.T) {
 	}
 
 }
+
+func TestStorage_DelNode(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+
+	old, _ := s.Set(data{id: 1}, 1, 0, 0)
+	s.Del(1)
+	if !old.IsRemoved() {
+		t.Error("失败1")
+	}
+
+	// 删除后重新存储，controller仍持有原来的node，不能删除新的对象
+	n, _ := s.Set(data{id: 2}, 1, 0, 0)
+	if n == old || s.DelNode(old, RemoveEvicted) {
+		t.Error("失败2")
+	}
+	if _, ok := s.Get(1); !ok {
+		t.Error("失败3")
+	}
+
+	if !s.DelNode(n, RemoveEvicted) || s.Has(1) || s.DelNode(n, RemoveEvicted) {
+		t.Error("失败4")
+	}
+}
+
+func TestStorage_Update(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+
+	// 不存在的对象不新增
+	if n, ok := s.Update(data{id: 1}, 1, 0); n != nil || ok || s.Has(1) {
+		t.Error("失败1")
+	}
+
+	s.Set(data{id: 1}, 1, 0, 0)
+	if n, ok := s.Update(data{id: 2}, 1, 0); n == nil || !ok || s.Len() != 1 {
+		t.Error("失败2")
+	}
+	if n, ok := s.Get(1); !ok || n.Obj.(data).id != 2 {
+		t.Error("失败3")
+	}
+}
+
+func TestStorage_Sweep(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+
+	for i := 1; i <= 100; i++ {
+		expire := 0
+		if i%2 == 0 {
+			expire = 1
+		}
+		if _, ok := s.SetDirect(data{id: i}, uint64(i), expire, 0); !ok {
+			t.Error("失败1")
+		}
+	}
+	// 纳入淘汰管理的对象同样加入时间轮
+	for i := 101; i <= 110; i++ {
+		if _, ok := s.Set(data{id: i}, uint64(i)<<1, 1, 0); !ok {
+			t.Error("失败1")
+		}
+	}
+	// 过期时间没有变化，不重复加入
+	s.Set(data{id: 110}, 220, 1, 0)
+	if s.WheelLen() != 60 {
+		t.Error("失败2", s.WheelLen())
+	}
+
+	// 重新设置为不过期，时间轮中的项失效
+	s.SetDirect(data{id: 2}, 2, 0, 0)
+
+	var swept int
+	s.Sweep(uint32(time.Now().Unix())+2, func(n *internal.Node) {
+		if n.Obj != nil || n.Hash%2 != 0 || n.IsDirect() != (n.Hash <= 100) {
+			t.Error("失败3")
+		}
+		swept++
+	})
+	if swept != 59 || s.WheelLen() != 0 {
+		t.Error("失败4", swept)
+	}
+
+	if _, ok := s.Get(2); !ok {
+		t.Error("失败5")
+	}
+	if _, ok := s.Get(4); ok {
+		t.Error("失败6")
+	}
+	if _, ok := s.Get(5); !ok {
+		t.Error("失败7")
+	}
+}
+
+func TestStorage_SweepOverwrite(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+
+	// 反复重新存储同一个对象（过期时间推迟）不增加时间轮中的项
+	for i := 1; i <= 1000; i++ {
+		s.Set(data{id: i}, 1, i, 0)
+		s.SetDirect(data{id: i}, 2, i, 0)
+	}
+	if s.WheelLen() != 2 {
+		t.Error("失败1", s.WheelLen())
+	}
+
+	// 到期的项按推迟后的过期时间重新加入，对象不被删除
+	var swept int
+	s.Sweep(uint32(time.Now().Unix())+2, func(n *internal.Node) {
+		swept++
+	})
+	if swept != 0 || s.WheelLen() != 2 || !s.Has(1) || !s.Has(2) {
+		t.Error("失败2", swept, s.WheelLen())
+	}
+}
+
+func TestStorage_RemoveHook(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+
+	reasons := make(map[uint64]RemoveReason)
+	s.SetRemoveHook(func(hash uint64, value interface{}, reason RemoveReason) {
+		// 已经释放锁
+		if s.Has(hash) || value.(data).id != int(hash) {
+			t.Error("失败1")
+		}
+		reasons[hash] = reason
+	})
+
+	n, _ := s.Set(data{id: 1}, 1, 0, 0)
+	s.Set(data{id: 2}, 2, 1, 0)
+	s.Set(data{id: 3}, 3, 0, 0)
+
+	if !s.DelNode(n, RemoveEvicted) {
+		t.Error("失败2")
+	}
+	s.Sweep(uint32(time.Now().Unix())+2, func(n *internal.Node) {})
+	// 主动删除不回调
+	s.Del(3)
+
+	if len(reasons) != 2 || reasons[1] != RemoveEvicted || reasons[2] != RemoveExpired {
+		t.Error("失败3", reasons)
+	}
+}
+
+func TestStorage_TTLJitter(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+	j := internal.NewTTLJitter(20)
+	j.SetTopic(1, 0)
+	s.SetTTLJitter(j)
+
+	now := internal.Now()
+	used := make(map[uint32]bool)
+	for i := 0; i < 100; i++ {
+		n, _ := s.Set(data{id: i}, uint64(i), 100, 0)
+		if n.Expire < now+80 || n.Expire > internal.Now()+100 {
+			t.Fatal("失败1", n.Expire-now)
+		}
+		used[n.Expire] = true
+	}
+	if len(used) < 10 {
+		t.Error("失败2", len(used))
+	}
+
+	// topic单独设置不抖动，不过期的对象不受影响
+	for i := 200; i < 220; i++ {
+		if n, _ := s.Set(data{id: i}, uint64(i), 100, 1); n.Expire < now+100 {
+			t.Error("失败3")
+		}
+	}
+	if n, _ := s.Set(data{id: 300}, 300, 0, 0); n.Expire != math.MaxUint32 {
+		t.Error("失败4")
+	}
+}
+
+func TestStorage_Expire(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+	now := internal.Now()
+
+	s.Set(data{id: 1}, 1, 100, 0)
+	s.Set(data{id: 2}, 2, 0, 0)
+	if expire, ok := s.ExpireOf(1); !ok || expire < now+100 {
+		t.Error("失败1")
+	}
+	if _, ok := s.ExpireOf(3); ok || s.Expire(3, 10) || s.Touch(3, 10) {
+		t.Error("失败2")
+	}
+
+	// 延长只对晚于原来的过期时间、设置了过期时间的对象有效
+	if !s.Touch(1, 10) || !s.Touch(2, 10) {
+		t.Error("失败3")
+	}
+	if expire, _ := s.ExpireOf(1); expire < now+100 {
+		t.Error("失败4")
+	}
+	if expire, _ := s.ExpireOf(2); expire != math.MaxUint32 {
+		t.Error("失败5")
+	}
+	s.Touch(1, 200)
+	if expire, _ := s.ExpireOf(1); expire < now+200 {
+		t.Error("失败6")
+	}
+	// 不会取消过期时间
+	if !s.Touch(1, 0) || !s.Touch(1, -1) {
+		t.Error("失败10")
+	}
+	if expire, _ := s.ExpireOf(1); expire == math.MaxUint32 || expire < now+200 {
+		t.Error("失败11", expire)
+	}
+
+	// 修改为更早的时间，不过期
+	s.Expire(1, 10)
+	s.Expire(2, 10)
+	if expire, _ := s.ExpireOf(2); expire > internal.Now()+10 {
+		t.Error("失败7")
+	}
+	s.Sweep(internal.Now()+11, func(n *internal.Node) {})
+	if s.Has(1) || s.Has(2) {
+		t.Error("失败8")
+	}
+	s.Set(data{id: 3}, 3, 10, 0)
+	s.Expire(3, 0)
+	s.Sweep(internal.Now()+100, func(n *internal.Node) {})
+	if expire, _ := s.ExpireOf(3); expire != math.MaxUint32 || s.WheelLen() != 0 {
+		t.Error("失败9")
+	}
+}
+
+func TestStorage_Sliding(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+	s.EnableSlidingExpiration()
+
+	clock := &testClock{now: time.Unix(1000000, 0)}
+	internal.SetClock(clock)
+	defer internal.SetClock(nil)
+
+	s.Set(data{id: 1}, 1, 10, 0)
+	s.Set(data{id: 2}, 2, 10, 0)
+
+	// 每次读取推迟到当前时间+TTL
+	for i := 0; i < 5; i++ {
+		clock.add(5 * time.Second)
+		s.Get(1)
+		if _, _, expire, _ := s.GetValue(1); expire != internal.Now()+10 {
+			t.Error("失败1", i)
+		}
+		s.Sweep(internal.Now(), func(n *internal.Node) {})
+	}
+	// 没有读取的对象按原来的时间过期，被推迟的对象按新的时间重新加入时间轮
+	if s.Has(2) || !s.Has(1) || s.WheelLen() != 1 {
+		t.Error("失败2", s.WheelLen())
+	}
+
+	// 已经过期的对象不推迟
+	clock.add(11 * time.Second)
+	if _, _, expire, _ := s.GetValue(1); expire != 1000035 {
+		t.Error("失败3", expire)
+	}
+	s.Sweep(internal.Now(), func(n *internal.Node) {})
+	if s.Has(1) {
+		t.Error("失败4")
+	}
+
+	// 无锁读取不加锁，不推迟过期时间（Options不允许同时启用）
+	s = NewStorage(internal.NodeUnitRestTime)
+	s.EnableSlidingExpiration()
+	s.EnableLockFreeReads()
+	s.Set(data{id: 3}, 3, 10, 0)
+	expire := internal.Now() + 10
+	clock.add(5 * time.Second)
+	if n, ok := s.Get(3); !ok || n.GetExpire() != expire {
+		t.Error("失败5")
+	}
+	if _, _, e, ok := s.GetValue(3); !ok || e != expire {
+		t.Error("失败6", e)
+	}
+	clock.add(6 * time.Second)
+	s.Sweep(internal.Now(), func(n *internal.Node) {})
+	if s.Has(3) {
+		t.Error("失败7")
+	}
+}
+
+type testClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *testClock) add(d time.Duration) {
+	c.Lock()
+	c.now = c.now.Add(d)
+	c.Unlock()
+	internal.RefreshClock()
+}
+
+func TestStorage_Peek(t *testing.T) {
+
+	s := NewStorage(internal.NodeUnitRestTime)
+	s.EnableSlidingExpiration()
+
+	n, _ := s.Set(data{id: 1}, 1, 100, 0)
+	expire := n.Expire
+	n.SetPinned(true)
+
+	// 不计入访问次数，也不推迟滑动过期的时间
+	obj, meta, ok := s.Peek(1)
+	if !ok || obj.(data).id != 1 || meta.Expire != expire || !meta.Pinned || meta.Queue != internal.QueueNone {
+		t.Error("失败1", meta)
+	}
+	if n.GetCurrentCount() != 0 {
+		t.Error("失败2")
+	}
+	if _, _, ok = s.Peek(2); ok {
+		t.Error("失败3")
+	}
+
+	// 无锁读取
+	s.EnableLockFreeReads()
+	if obj, meta, ok = s.Peek(1); !ok || obj.(data).id != 1 || meta.Expire != expire || n.GetCurrentCount() != 0 {
+		t.Error("失败4")
+	}
+	s.Del(1)
+	if _, _, ok = s.Peek(1); ok {
+		t.Error("失败5")
+	}
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"math"
+)
+
+const (
+	// 时间轮的层数
+	wheelLevels = 4
+	// 每层的槽位个数的位数（64个槽位）
+	wheelBits = 6
+	wheelSize = 1 << wheelBits
+	wheelMask = wheelSize - 1
+	// 时间轮能表示的最大时间跨度（秒），超出则先放入最高层的最后一个槽位，到期后重新计算
+	wheelMaxDelta = 1<<(wheelBits*wheelLevels) - 1
+)
+
+// wheelEntry 时间轮中的一项，记录加入时node的hash和过期时间，node被重新使用或者过期时间被修改后用于判断此项是否失效
+type wheelEntry struct {
+	node   *Node
+	hash   uint64
+	expire uint32
+}
+
+// TimingWheel 分层时间轮，以秒为单位，用于按Node.Expire删除过期的node。
+// 共4层，每层64个槽位，第n层每个槽位的跨度为64^n秒（最大约194天）。加入的时间复杂度为O(1)，每秒只处理到期的槽位，
+// 高层的槽位到期后将其中的node重新分配到低层，不需要扫描所有node。
+// TimingWheel不是并发安全的，由调用者加锁（如storage.Storage的锁）。
+type TimingWheel struct {
+	// 当前已经处理到的时间（Unix time，单位为秒）
+	current uint32
+	slots   [wheelLevels][wheelSize][]wheelEntry
+	count   int
+}
+
+func NewTimingWheel(now uint32) (w *TimingWheel) {
+	return &TimingWheel{current: now}
+}
+
+// Add 按node当前的过期时间加入时间轮，已经过期的node在下一秒处理
+func (w *TimingWheel) Add(n *Node) {
+	w.place(wheelEntry{node: n, hash: n.Hash, expire: n.Expire}, w.current+1)
+	w.count++
+}
+
+// Schedule 保证node在过期时间到达时被处理：过期时间早于最后加入的项（Node.WheelExpire）时才加入新的项；
+// 过期时间被推迟（如滑动过期）则不加入，由最后加入的项到期后调用Reschedule()重新加入，同一个node最多只有一个项需要重新加入
+func (w *TimingWheel) Schedule(n *Node) {
+	if n.Expire == math.MaxUint32 {
+		return
+	}
+	if n.WheelExpire == 0 || n.Expire < n.WheelExpire {
+		n.WheelExpire = n.Expire
+		w.Add(n)
+	}
+}
+
+// Reschedule 项到期时node的过期时间已经被推迟，expire为此项的过期时间：是最后加入的项则按node当前的过期时间重新加入，否则丢弃
+func (w *TimingWheel) Reschedule(n *Node, expire uint32) {
+	if expire != n.WheelExpire {
+		return
+	}
+	n.WheelExpire = 0
+	w.Schedule(n)
+}
+
+// place 将e放入对应的槽位，base为最早可以处理的时间
+func (w *TimingWheel) place(e wheelEntry, base uint32) {
+	expire := e.expire
+	if expire < base {
+		expire = base
+	}
+
+	delta := expire - base
+	if delta > wheelMaxDelta {
+		delta = wheelMaxDelta
+		expire = base + wheelMaxDelta
+	}
+
+	level := 0
+	for level < wheelLevels-1 && delta >= 1<<(wheelBits*uint(level+1)) {
+		level++
+	}
+
+	index := (expire >> (wheelBits * uint(level))) & wheelMask
+	w.slots[level][index] = append(w.slots[level][index], e)
+}
+
+// Advance 处理到now为止所有到期的槽位，对每一个到期的项调用fn。
+// fn参数中的hash、expire为加入时记录的值，node可能已经被重新使用，需要调用者判断。
+func (w *TimingWheel) Advance(now uint32, fn func(n *Node, hash uint64, expire uint32)) {
+	for w.current < now {
+		t := w.current + 1
+
+		// 先从高层开始，将到期槽位中的项重新分配到低层
+		for level := wheelLevels - 1; level > 0; level-- {
+			shift := wheelBits * uint(level)
+			if t&(1<<shift-1) != 0 {
+				continue
+			}
+			index := (t >> shift) & wheelMask
+			entries := w.slots[level][index]
+			w.slots[level][index] = nil
+			for k := range entries {
+				w.place(entries[k], t)
+				entries[k] = wheelEntry{}
+			}
+			if w.slots[level][index] == nil {
+				w.slots[level][index] = entries[:0]
+			}
+		}
+
+		index := t & wheelMask
+		entries := w.slots[0][index]
+		w.slots[0][index] = nil
+		for k := range entries {
+			if entries[k].expire <= t {
+				w.count--
+				fn(entries[k].node, entries[k].hash, entries[k].expire)
+			} else {
+				// 超出时间跨度的项，重新计算
+				w.place(entries[k], t+1)
+			}
+			entries[k] = wheelEntry{}
+		}
+		if w.slots[0][index] == nil {
+			w.slots[0][index] = entries[:0]
+		}
+
+		w.current = t
+	}
+}
+
+// Len 时间轮中项的个数（包括已经失效的项）
+func (w *TimingWheel) Len() int {
+	return w.count
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"testing"
+)
+
+func TestTimingWheel_Total(t *testing.T) {
+
+	var now = uint32(1000000)
+	w := NewTimingWheel(now)
+
+	expires := []uint32{now - 10, now + 1, now + 63, now + 64, now + 4095, now + 4096, now + 300000, now + 20000000}
+	for k := range expires {
+		w.Add(&Node{Hash: uint64(k), Expire: expires[k]})
+	}
+	if w.Len() != len(expires) {
+		t.Error("失败1")
+	}
+
+	fired := make(map[uint64]uint32)
+	var current uint32
+	fn := func(n *Node, hash uint64, expire uint32) {
+		if n.Hash != hash || n.Expire != expire {
+			t.Error("失败2")
+		}
+		fired[hash] = current
+	}
+
+	// 逐秒推进，检查每一项在到期的那一秒被处理
+	for current = now + 1; current <= now+300000; current++ {
+		w.Advance(current, fn)
+	}
+	for k, v := range expires[:7] {
+		want := v
+		if want <= now {
+			want = now + 1
+		}
+		if fired[uint64(k)] != want {
+			t.Errorf("失败3 %d: %d != %d", k, fired[uint64(k)], want)
+		}
+	}
+
+	// 超出时间跨度的项，一次推进多秒
+	if _, ok := fired[7]; ok {
+		t.Error("失败4")
+	}
+	current = now + 20000000
+	w.Advance(current, fn)
+	if _, ok := fired[7]; !ok || w.Len() != 0 {
+		t.Error("失败5")
+	}
+}
+
+func TestTimingWheel_Schedule(t *testing.T) {
+
+	var now = uint32(1000000)
+	w := NewTimingWheel(now)
+
+	n := &Node{Hash: 1, Expire: now + 10}
+	w.Schedule(n)
+	// 推迟不加入新的项，提前则加入
+	n.Expire = now + 20
+	w.Schedule(n)
+	if w.Len() != 1 || n.WheelExpire != now+10 {
+		t.Error("失败1")
+	}
+	n.Expire = now + 5
+	w.Schedule(n)
+	if w.Len() != 2 || n.WheelExpire != now+5 {
+		t.Error("失败2")
+	}
+
+	// 到期时过期时间已经被推迟：只有最后加入的项重新加入
+	n.Expire = now + 30
+	var fired []uint32
+	fn := func(n *Node, hash uint64, expire uint32) {
+		if n.Expire > now+30 {
+			t.Error("失败3")
+		}
+		if n.Expire > expire {
+			w.Reschedule(n, expire)
+			return
+		}
+		fired = append(fired, expire)
+	}
+	w.Advance(now+29, fn)
+	if w.Len() != 1 || n.WheelExpire != now+30 || len(fired) != 0 {
+		t.Error("失败4", w.Len())
+	}
+	w.Advance(now+30, fn)
+	if w.Len() != 0 || len(fired) != 1 {
+		t.Error("失败5")
+	}
+}
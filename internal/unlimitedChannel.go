@@ -3,6 +3,7 @@ package internal
 import (
 	"container/list"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -49,27 +50,48 @@ func (c *chanCache) set(nc nodeChan) {
 
 // UnlimitedChannel 是一个不限定容量的channel。这样做只为应对存入对象超级密集的情况（如性能测试）。
 type UnlimitedChannel struct {
+	// 已经写入、还没有读取的node个数
+	count int64
+
 	chanelCache *chanCache
 
 	channelList *list.List
 	lock        sync.Mutex
 
-	head, tail *nodeChan
+	// 当前读取、写入的channel（nodeChan），在lock内修改，读写时不加锁读取
+	head, tail atomic.Value
+
+	// 写入node后通知读取方，容量为1，读取方没有处理之前的通知时不再重复通知
+	notify chan struct{}
+
+	// 积压的node个数上限，为0则不限制。达到上限后由写入方决定如何处理（见Full()、WaitNotFull()），SetNode()本身不阻塞
+	limit int64
+	// 在WaitNotFull()中等待的写入方个数，积压下降到上限的一半或者读取完时关闭space唤醒
+	waiters  int32
+	waitLock sync.Mutex
+	space    chan struct{}
 }
 
 func NewUnlimitedChannel() (s *UnlimitedChannel) {
+	return NewBoundedChannel(0)
+}
+
+// NewBoundedChannel 创建积压的node个数上限为limit的UnlimitedChannel，limit为0则不限制
+func NewBoundedChannel(limit int64) (s *UnlimitedChannel) {
 
 	s = &UnlimitedChannel{
 		channelList: list.New(),
 		chanelCache: newChanCache(),
+		notify:      make(chan struct{}, 1),
+		limit:       limit,
 	}
 
 	nc := s.chanelCache.get()
 	s.lock.Lock()
 	s.channelList.PushBack(nc)
 	s.lock.Unlock()
-	s.head = &nc
-	s.tail = &nc
+	s.head.Store(nc)
+	s.tail.Store(nc)
 	return s
 }
 
@@ -77,22 +99,26 @@ func (s *UnlimitedChannel) GetNode() (node *Node, ok bool) {
 
 	for {
 		select {
-		case node = <-*s.head:
+		case node = <-s.head.Load().(nodeChan):
+			s.read()
 			return node, true
 		default:
 			s.lock.Lock()
 			select {
-			case node = <-*s.head:
+			case node = <-s.head.Load().(nodeChan):
 				s.lock.Unlock()
+				s.read()
 				return node, true
 			default:
 				if s.channelList.Len() > 1 {
 					nc := s.channelList.Remove(s.channelList.Front()).(nodeChan)
 					s.chanelCache.set(nc)
-					nc = s.channelList.Front().Value.(nodeChan)
-					s.head = &nc
+					s.head.Store(s.channelList.Front().Value.(nodeChan))
 				} else {
 					s.lock.Unlock()
+					if atomic.LoadInt32(&s.waiters) > 0 {
+						s.wake()
+					}
 					return nil, false
 				}
 			}
@@ -102,22 +128,97 @@ func (s *UnlimitedChannel) GetNode() (node *Node, ok bool) {
 
 }
 
+// read 读取一个node后减少计数，积压下降到上限的一半时唤醒等待的写入方
+func (s *UnlimitedChannel) read() {
+	count := atomic.AddInt64(&s.count, -1)
+	if atomic.LoadInt32(&s.waiters) > 0 && count <= s.limit/2 {
+		s.wake()
+	}
+}
+
+// wake 唤醒在WaitNotFull()中等待的写入方
+func (s *UnlimitedChannel) wake() {
+	s.waitLock.Lock()
+	if s.space != nil {
+		close(s.space)
+		s.space = nil
+	}
+	s.waitLock.Unlock()
+}
+
+// Full 积压的node个数是否达到上限，没有设置上限则总是返回false
+func (s *UnlimitedChannel) Full() bool {
+	return s.limit > 0 && s.Len() >= s.limit
+}
+
+// WaitNotFull 积压的node个数达到上限时阻塞，直到读取方读取到上限的一半以下（返回true）或者done被关闭（返回false）
+func (s *UnlimitedChannel) WaitNotFull(done <-chan struct{}) (ok bool) {
+	if !s.Full() {
+		return true
+	}
+
+	atomic.AddInt32(&s.waiters, 1)
+	defer atomic.AddInt32(&s.waiters, -1)
+	for {
+		s.waitLock.Lock()
+		if s.space == nil {
+			s.space = make(chan struct{})
+		}
+		space := s.space
+		s.waitLock.Unlock()
+
+		// 先登记再检查，读取方在检查之后读取时一定会关闭space
+		if !s.Full() {
+			return true
+		}
+		select {
+		case <-space:
+		case <-done:
+			return false
+		}
+	}
+}
+
+// Len 已经写入、还没有读取的node个数
+func (s *UnlimitedChannel) Len() int64 {
+	return atomic.LoadInt64(&s.count)
+}
+
+// Notify 返回写入通知的channel。收到通知后应当调用GetNode()直到返回false，否则可能错过之后写入的node
+func (s *UnlimitedChannel) Notify() <-chan struct{} {
+	return s.notify
+}
+
+// signal 通知读取方，已经有未处理的通知时不再加锁发送
+func (s *UnlimitedChannel) signal() {
+	if len(s.notify) == 0 {
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
 func (s *UnlimitedChannel) SetNode(node *Node) {
 
+	// 先计数，避免读取方在计数前读取到node时计数为负数
+	atomic.AddInt64(&s.count, 1)
 	for {
 		select {
-		case *s.tail <- node:
+		case s.tail.Load().(nodeChan) <- node:
+			s.signal()
 			return
 		default:
 			s.lock.Lock()
 			select {
-			case *s.tail <- node:
+			case s.tail.Load().(nodeChan) <- node:
 				s.lock.Unlock()
+				s.signal()
 				return
 			default:
 				nc := s.chanelCache.get()
 				s.channelList.PushBack(nc)
-				s.tail = &nc
+				s.tail.Store(nc)
 			}
 			s.lock.Unlock()
 		}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"sync"
 	"testing"
 	"time"
 )
@@ -37,10 +38,91 @@ func TestUnlimitedChannel_Total(t *testing.T) {
 
 }
 
+func TestUnlimitedChannel_Notify(t *testing.T) {
+
+	sc := NewUnlimitedChannel()
+
+	select {
+	case <-sc.Notify():
+		t.Error("失败1")
+	default:
+	}
+
+	// 多次写入只保留一个通知
+	sc.SetNode(&Node{Hash: 1})
+	sc.SetNode(&Node{Hash: 2})
+	select {
+	case <-sc.Notify():
+	case <-time.After(time.Second):
+		t.Error("失败2")
+	}
+	select {
+	case <-sc.Notify():
+		t.Error("失败3")
+	default:
+	}
+
+	if n, _ := sc.GetNode(); n.Hash != 1 {
+		t.Error("失败4")
+	}
+	if n, _ := sc.GetNode(); n.Hash != 2 {
+		t.Error("失败5")
+	}
+}
+
+func TestUnlimitedChannel_Bounded(t *testing.T) {
+
+	sc := NewBoundedChannel(4)
+
+	for i := 0; i < 4; i++ {
+		if sc.Full() {
+			t.Fatal("失败1", i)
+		}
+		sc.SetNode(&Node{Hash: uint64(i)})
+	}
+	if !sc.Full() || sc.Len() != 4 {
+		t.Fatal("失败2", sc.Len())
+	}
+
+	// 积压达到上限时阻塞，读取到上限的一半以下后返回
+	done := make(chan struct{})
+	go func() {
+		sc.WaitNotFull(nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("失败3")
+	case <-time.After(time.Millisecond * 10):
+	}
+
+	sc.GetNode()
+	sc.GetNode()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("失败4")
+	}
+
+	// done被关闭后不再等待
+	sc.SetNode(&Node{Hash: 4})
+	sc.SetNode(&Node{Hash: 5})
+	cancel := make(chan struct{})
+	close(cancel)
+	if sc.WaitNotFull(cancel) {
+		t.Error("失败5")
+	}
+
+	// 没有设置上限则不会满
+	if NewUnlimitedChannel().Full() {
+		t.Error("失败6")
+	}
+}
+
 var sc = NewUnlimitedChannel()
 
-func set(t *testing.T) {
-	t.Parallel()
+func set(wg *sync.WaitGroup) {
+	defer wg.Done()
 	for i := 0; i < 10000000; i++ {
 		sc.SetNode(&Node{})
 		// time.Sleep(time.Microsecond*100)
@@ -48,7 +130,6 @@ func set(t *testing.T) {
 }
 
 func get(t *testing.T) {
-	t.Parallel()
 	var count uint64
 	for {
 		_, ok := sc.GetNode()
@@ -70,17 +151,18 @@ func get(t *testing.T) {
 	t.Logf("count:%d", count)
 }
 
+// 写入和读取不能使用t.Parallel()，GOMAXPROCS为1时并行的子测试只能逐个运行，get会一直等待
 func TestUnlimitedChannel_SyncGetAndSet(t *testing.T) {
 
 	t.Logf("start")
 
-	t.Run("group", func(t *testing.T) {
-		t.Run("set1", set)
-		t.Run("set2", set)
-		t.Run("set3", set)
-		t.Run("set4", set)
-		t.Run("get", get)
-	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go set(&wg)
+	}
+	get(t)
+	wg.Wait()
 
 	t.Logf("end")
 }
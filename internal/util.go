@@ -15,6 +15,9 @@ func Bytes2String(b []byte) string {
 }
 
 func String2Bytes(s string) []byte {
+	if len(s) == 0 {
+		return []byte{}
+	}
 	x := (*[2]uintptr)(unsafe.Pointer(&s))
 	h := [3]uintptr{x[0], x[1], x[1]}
 	return *(*[]byte)(unsafe.Pointer(&h))
@@ -0,0 +1,103 @@
+package objectCache
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"objectCache/transport"
+	"sync/atomic"
+	"time"
+)
+
+// Transport 分布式失效使用的消息传输，详见transport.Transport
+type Transport = transport.Transport
+
+// 失效消息的类型
+const (
+	msgDel       = byte(1)
+	msgDropTopic = byte(2)
+)
+
+// 消息头：类型（1字节）+ 发送者编号（8字节）
+const msgHeaderSize = 9
+
+// invalidationBus 分布式失效。本地调用Del()、DropTopic()，以及每次成功存储对象（Set()、SetDirect()等）时发布失效消息，
+// 收到其他进程的消息后只在本地删除，不再发布
+type invalidationBus struct {
+	transport Transport
+	// 发送者编号，用于忽略自己发布的消息（如组播会发送给自己）
+	origin uint64
+
+	// 发布失败的次数
+	publishErrors uint64
+}
+
+// newInvalidationBus 创建并订阅消息，apply在Transport的goroutine中调用
+func newInvalidationBus(t Transport, apply func(kind byte, payload []byte)) (b *invalidationBus, err error) {
+	b = &invalidationBus{
+		transport: t,
+		origin:    rand.New(rand.NewSource(time.Now().UnixNano())).Uint64(),
+	}
+
+	err = t.Subscribe(func(msg []byte) {
+		if len(msg) < msgHeaderSize || binary.LittleEndian.Uint64(msg[1:msgHeaderSize]) == b.origin {
+			return
+		}
+		apply(msg[0], msg[msgHeaderSize:])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// publish 发布消息。失效消息发布失败不影响本地删除，只记录次数
+func (b *invalidationBus) publish(kind byte, payload []byte) {
+	msg := make([]byte, msgHeaderSize+len(payload))
+	msg[0] = kind
+	binary.LittleEndian.PutUint64(msg[1:msgHeaderSize], b.origin)
+	copy(msg[msgHeaderSize:], payload)
+
+	if b.transport.Publish(msg) != nil {
+		atomic.AddUint64(&b.publishErrors, 1)
+	}
+}
+
+// publishDel 通知其他进程删除hash对应的对象
+func (c *objectCache) publishDel(hash uint64) {
+	if c.bus == nil {
+		return
+	}
+	var payload [8]byte
+	binary.LittleEndian.PutUint64(payload[:], hash)
+	c.bus.publish(msgDel, payload[:])
+}
+
+// publishDropTopic 通知其他进程删除topic下的所有对象
+func (c *objectCache) publishDropTopic(topic string) {
+	if c.bus == nil {
+		return
+	}
+	c.bus.publish(msgDropTopic, []byte(topic))
+}
+
+// applyInvalidation 在本地执行其他进程发布的失效消息，不再发布
+func (c *objectCache) applyInvalidation(kind byte, payload []byte) {
+	switch kind {
+	case msgDel:
+		if len(payload) != 8 {
+			return
+		}
+		hash := binary.LittleEndian.Uint64(payload)
+		c.delHash(hash, c.segments.Index(hash))
+	case msgDropTopic:
+		c.dropTopic(string(payload))
+	}
+}
+
+// GetInvalidationErrors 获取失效消息发布失败的次数，缓存没有初始化或者没有启用分布式失效则返回0
+func GetInvalidationErrors() (count uint64) {
+	if c == nil || c.bus == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.bus.publishErrors)
+}
@@ -0,0 +1,172 @@
+package objectCache
+
+import (
+	"encoding/binary"
+	"errors"
+	"objectCache/internal"
+	"objectCache/transport"
+	"testing"
+)
+
+func TestInvalidation_Total(t *testing.T) {
+
+	hub := transport.NewHub()
+	if err := InitObjectCacheWithOptions(Options{Transport: hub.NewTransport()}); err != nil {
+		t.Fatal("失败1", err)
+	}
+	if c.bus == nil {
+		t.Skip("缓存已经初始化")
+	}
+
+	// 模拟另一个进程
+	remote := hub.NewTransport()
+	var received [][]byte
+	_ = remote.Subscribe(func(msg []byte) { received = append(received, msg) })
+	remoteMsg := func(kind byte, payload []byte) []byte {
+		msg := make([]byte, msgHeaderSize, msgHeaderSize+len(payload))
+		msg[0] = kind
+		binary.LittleEndian.PutUint64(msg[1:], c.bus.origin+1)
+		return append(msg, payload...)
+	}
+
+	// 存储、删除都发布消息
+	_ = Set([]byte("inv1"), 1, 0)
+	if !Del([]byte("inv1")) || len(received) != 2 || received[0][0] != msgDel || received[1][0] != msgDel {
+		t.Error("失败2")
+	}
+	hash := internal.HashFunc(append([]byte("inv1"), defaultTopic...))
+	if binary.LittleEndian.Uint64(received[0][msgHeaderSize:]) != hash || binary.LittleEndian.Uint64(received[1][msgHeaderSize:]) != hash {
+		t.Error("失败3")
+	}
+
+	// 收到其他进程的消息只在本地删除，不再发布
+	_ = Set([]byte("inv1"), 1, 0)
+	received = nil
+	var payload [8]byte
+	binary.LittleEndian.PutUint64(payload[:], hash)
+	_ = remote.Publish(remoteMsg(msgDel, payload[:]))
+	if _, ok := Get([]byte("inv1")); ok || len(received) != 0 {
+		t.Error("失败4")
+	}
+
+	// 自己发布的消息被忽略
+	_ = Set([]byte("inv1"), 1, 0)
+	msg := remoteMsg(msgDel, payload[:])
+	binary.LittleEndian.PutUint64(msg[1:], c.bus.origin)
+	_ = remote.Publish(msg)
+	if _, ok := Get([]byte("inv1")); !ok {
+		t.Error("失败5")
+	}
+
+	// 按topic删除
+	for i := int64(0); i < 3; i++ {
+		_ = SetIntByTopic("invTopic1", i, i, 0)
+	}
+	SetIntDirectByTopic("invTopic1", 10, 10, 0)
+	_ = SetIntByTopic("invTopic2", 1, 1, 0)
+	received = nil
+	_ = remote.Publish(remoteMsg(msgDropTopic, []byte("invTopic1")))
+	if _, ok := GetIntByTopic("invTopic1", 1); ok {
+		t.Error("失败6")
+	}
+	if _, ok := GetIntDirectByTopic("invTopic1", 10); ok {
+		t.Error("失败7")
+	}
+	if _, ok := GetIntByTopic("invTopic2", 1); !ok || len(received) != 0 {
+		t.Error("失败8")
+	}
+
+	if DropTopic("invTopic2") != 1 || len(received) != 1 || string(received[0][msgHeaderSize:]) != "invTopic2" {
+		t.Error("失败9")
+	}
+	if DropTopic("unknownTopic") != 0 {
+		t.Error("失败10")
+	}
+
+	// 替换已经存在的对象、新增对象、不纳入淘汰管理的对象都通知其他进程删除
+	received = nil
+	_ = Set([]byte("inv1"), 2, 0)
+	_ = Set([]byte("inv2"), 2, 0)
+	SetDirect([]byte("inv3"), 3, 0)
+	if len(received) != 3 || received[0][0] != msgDel || binary.LittleEndian.Uint64(received[0][msgHeaderSize:]) != hash {
+		t.Error("失败11", len(received))
+	}
+	if binary.LittleEndian.Uint64(received[1][msgHeaderSize:]) != KeyHash("", []byte("inv2")) ||
+		binary.LittleEndian.Uint64(received[2][msgHeaderSize:]) != KeyHash("", []byte("inv3")) {
+		t.Error("失败12")
+	}
+}
+
+// newReplica 创建一个连接到hub的缓存（不替换c），模拟另一个进程
+func newReplica(t *testing.T, hub *transport.Hub) (r *objectCache) {
+	opts, err := Options{Transport: hub.NewTransport()}.normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, err = newObjectCache(opts); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestInvalidation_Replicas(t *testing.T) {
+	InitDefaultObjectCache()
+	saved := c
+	hub := transport.NewHub()
+	a, b := newReplica(t, hub), newReplica(t, hub)
+	defer func() {
+		c = saved
+		_ = a.close()
+		_ = b.close()
+	}()
+
+	// B持有旧的对象，A存储本地不存在的对象后B删除
+	c = b
+	_ = Set([]byte("replica"), "old", 0)
+	SetDirect([]byte("replicaDirect"), "old", 0)
+
+	c = a
+	if _, ok := Get([]byte("replica")); ok {
+		t.Fatal("失败1")
+	}
+	_ = Set([]byte("replica"), "new", 0)
+	SetDirect([]byte("replicaDirect"), "new", 0)
+
+	c = b
+	if _, ok := Get([]byte("replica")); ok {
+		t.Error("失败2")
+	}
+	if _, ok := GetDirect([]byte("replicaDirect")); ok {
+		t.Error("失败3")
+	}
+
+	// A自己的对象不受影响
+	c = a
+	if obj, ok := Get([]byte("replica")); !ok || obj.(string) != "new" {
+		t.Error("失败4")
+	}
+}
+
+func TestInvalidation_SubscribeError(t *testing.T) {
+	InitDefaultObjectCache()
+	saved := c
+	c = nil
+	defer func() {
+		if c != saved {
+			_ = Close()
+		}
+		c = saved
+	}()
+
+	// 订阅失败不保留任何状态，错误可以用errors.Is判断
+	failed := transport.NewHub().NewTransport()
+	_ = failed.Close()
+	if err := New(WithOptions(Options{Transport: failed})); !errors.Is(err, transport.ErrClosed) || c != nil {
+		t.Fatal("失败1", err)
+	}
+
+	// 可以再次初始化
+	if err := New(); err != nil || c == nil || c.isClosed() {
+		t.Error("失败2", err)
+	}
+}
@@ -0,0 +1,277 @@
+package objectCache
+
+import (
+	"fmt"
+	"math"
+	"objectCache/internal"
+	"objectCache/internal/storage"
+	"runtime"
+)
+
+// EvictionConfig 淘汰模型的参数，字段为0（千分比字段为nil）则使用默认值，详见internal.EvictionConfig
+type EvictionConfig = internal.EvictionConfig
+
+// Permille 设置EvictionConfig中0也是合法值的千分比字段（如DestroyStability、AdaptiveHysteresis），如Permille(0)表示使用0而不是默认值
+func Permille(v uint64) *uint64 {
+	return internal.Permille(v)
+}
+
+// DefaultEvictionConfig 返回默认的淘汰模型参数
+func DefaultEvictionConfig() EvictionConfig {
+	return internal.DefaultEvictionConfig()
+}
+
+const (
+	// 序列化存储模式下编码后对象的默认最大大小
+	defaultMaxValueSize = 16 << 20
+	// 编码后对象大小的上限，node中的长度为uint32，字节区的大小也不能超过4GB
+	maxValueSize = 1 << 30
+	// 默认的topic个数上限
+	defaultMaxTopics = 1 << 16
+)
+
+// Clock 时间来源，测试时可以注入自己控制的时间，详见Options.Clock
+type Clock = internal.Clock
+
+// Logger 日志输出，*log.Logger满足此接口，详见Options.Logger
+type Logger = internal.Logger
+
+// RemoveFunc 对象被淘汰、过期删除后的回调，hash为键值的hash（见KeyHash()），obj为删除前存储的对象。
+// 回调中可以调用缓存的接口
+type RemoveFunc func(hash uint64, obj interface{})
+
+// NoExpire 存储时作为expireSecond，对象不过期（不使用Options.DefaultTTL）
+const NoExpire = -1
+
+// NoJitter 作为TTLConfig.Jitter，topic的过期时间不抖动（不使用Options.TTLJitter）
+const NoJitter = -1
+
+// TTLConfig topic的过期时间配置，字段为0则使用缓存的配置（Options.DefaultTTL、Options.TTLJitter）
+type TTLConfig struct {
+	// 存储时expireSecond为0的对象的过期时间（单位是秒），为NoExpire则不过期
+	Default int `json:"default" yaml:"default"`
+	// 过期时间的随机抖动（百分比，[0, 100]），为NoJitter则不抖动
+	Jitter int `json:"jitter" yaml:"jitter"`
+}
+
+// validate 检查参数
+func (t TTLConfig) validate() (err error) {
+	if t.Default < NoExpire {
+		return fmt.Errorf("Default(%d)不能小于%d", t.Default, NoExpire)
+	}
+	if t.Jitter < NoJitter || t.Jitter > 100 {
+		return fmt.Errorf("Jitter(%d)需要在[%d, 100]之间", t.Jitter, NoJitter)
+	}
+	return nil
+}
+
+// CapacityPolicy 对象数量达到最大缓存数量后，新增对象的处理策略
+type CapacityPolicy int
+
+const (
+	// CapacitySoft 最大缓存数量只是一个参考值，由淘汰算法尽量满足（默认）
+	CapacitySoft CapacityPolicy = iota
+	// CapacityEvict 严格限制对象数量，新增对象前同步淘汰代价最低的对象（destroyQueue、低等级的restQueue优先）
+	CapacityEvict
+	// CapacityReject 严格限制对象数量，拒绝新增对象并返回ErrRejected
+	CapacityReject
+)
+
+// IntakePolicy controller积压的新增对象达到上限（Options.IntakeLimit）后，新增对象的处理策略
+type IntakePolicy int
+
+const (
+	// IntakeBlock 阻塞写入，直到controller处理到上限的一半以下（默认）
+	IntakeBlock IntakePolicy = iota
+	// IntakeDirect 新增对象不纳入淘汰管理，作为SetDirect()存储的对象，只会过期或者被删除
+	IntakeDirect
+	// IntakeEvict 对象数量达到开始淘汰的比例（EvictionConfig.EliminateThreshold）时，由controller同步处理积压的对象并淘汰代价最低的对象，
+	// 写入方阻塞到处理完成；没有达到时与IntakeBlock相同
+	IntakeEvict
+)
+
+// Options 缓存的配置，可以直接构造，也可以从JSON、YAML等配置文件解析（接口、函数类型的字段除外），再通过New(WithOptions())使用
+type Options struct {
+	// 最大缓存数量，其范围为[1w ~ 10000w]。InitObjectCacheWithOptions()在没有在这个范围时采用默认值100w，New()则返回错误（为0使用默认值）
+	ObjMaxCount int32 `json:"obj_max_count" yaml:"obj_max_count"`
+	// 序列化存储模式下所有对象编码后的最大字节数，超出时按CapacityPolicy同步淘汰（CapacitySoft、CapacityEvict）或者返回ErrRejected，
+	// 需要同时设置Codec，为0则不限制
+	MaxBytes int64 `json:"max_bytes" yaml:"max_bytes"`
+
+	// 分段（storage.Storage）的个数，需要为2的幂，最大为65536。为0则根据GOMAXPROCS计算（GOMAXPROCS*16向上取2的幂，最小为256）
+	Shards int `json:"shards" yaml:"shards"`
+
+	// controller的分片个数，需要为2的幂并且不大于Shards，每个分片使用一个协程并行处理新增的对象和淘汰判断。
+	// 为0则根据GOMAXPROCS计算（GOMAXPROCS向上取2的幂，不大于Shards）
+	ControllerShards int `json:"controller_shards" yaml:"controller_shards"`
+
+	// controller积压（已经存入、还没有放入淘汰队列）的对象数量上限，平均分配到每个分片，为0则不限制。
+	// 写入速率持续超过controller的处理速度时，限制积压占用的内存
+	IntakeLimit int `json:"intake_limit" yaml:"intake_limit"`
+	// 积压的对象数量达到IntakeLimit后的处理策略
+	IntakePolicy IntakePolicy `json:"intake_policy" yaml:"intake_policy"`
+
+	// 启用无锁读取：读取不加锁，写入仍按分段串行执行，适用于读多写少（如读写比例1000:1）并且CPU核数较多的场景。
+	// 不能与序列化存储模式（Codec）同时使用
+	LockFreeReads bool `json:"lock_free_reads" yaml:"lock_free_reads"`
+
+	// 对象数量达到ObjMaxCount后的处理策略
+	CapacityPolicy CapacityPolicy `json:"capacity_policy" yaml:"capacity_policy"`
+
+	// 淘汰模型的参数
+	Eviction EvictionConfig `json:"eviction" yaml:"eviction"`
+
+	// 准入策略
+	Admission AdmissionConfig `json:"admission" yaml:"admission"`
+
+	// 存储时expireSecond为0的对象的过期时间（单位是秒），为0则不过期。设置后需要不过期的对象使用NoExpire
+	DefaultTTL int `json:"default_ttl" yaml:"default_ttl"`
+	// 过期时间的随机抖动（百分比，[0, 100]），为0则不抖动。同时存储的大量对象的过期时间分散在[ttl-ttl*TTLJitter/100, ttl]之间，
+	// 避免在同一秒过期后同时回源
+	TTLJitter int `json:"ttl_jitter" yaml:"ttl_jitter"`
+	// 滑动过期：每次读取（Get()等）对象时把过期时间推迟到当前时间+存储时设置的过期时间（不包括随机抖动），适用于会话等场景。
+	// 不能与LockFreeReads同时使用
+	SlidingExpiration bool `json:"sliding_expiration" yaml:"sliding_expiration"`
+	// 单独设置topic的默认过期时间、随机抖动，覆盖DefaultTTL、TTLJitter。默认topic使用空字符串，也可以通过SetTopicTTL()设置
+	TopicTTL map[string]TTLConfig `json:"topic_ttl" yaml:"topic_ttl"`
+
+	// 默认topic以外的topic个数上限，为0则使用默认值65536。每个topic占用一个编号，编号不回收（DropTopic()不释放），
+	// 达到上限后存储新topic的对象返回ErrRejected（SetDirect()等不存储）。按租户、请求等生成topic名称时需要限制个数
+	MaxTopics int `json:"max_topics" yaml:"max_topics"`
+
+	// 分布式失效的消息传输，为nil则不启用。启用后Del()、DropTopic()，以及每次成功存储对象（Set()、SetDirect()等）会通知其他进程
+	// 删除同一个对象
+	Transport Transport `json:"-" yaml:"-"`
+
+	// 对象的编解码，不为nil则启用序列化存储模式：对象编码后保存在每个分段的字节区中，node只记录偏移和长度，
+	// 缓存大量对象时减小GC扫描的开销。Get()返回的是解码后的新对象
+	Codec Codec `json:"-" yaml:"-"`
+	// 序列化存储模式下每个分段字节区的初始大小（字节），为0则使用默认值64KB
+	ArenaSize uint32 `json:"arena_size" yaml:"arena_size"`
+	// 序列化存储模式下编码后对象的最大大小（字节），超过则返回ErrTooLarge，为0则使用默认值16MB
+	MaxValueSize int `json:"max_value_size" yaml:"max_value_size"`
+	// 序列化存储模式下字节区使用mmap分配在Go堆外（仅Linux），适用于数十GB的缓存，需要同时设置Codec
+	OffHeap bool `json:"off_heap" yaml:"off_heap"`
+
+	// 时间来源，为nil则使用系统时间。缓存使用后台协程每秒（系统时间的整秒）读取一次的粗粒度时间，注入的时间被修改后最晚1秒生效
+	Clock Clock `json:"-" yaml:"-"`
+
+	// 日志输出（如*log.Logger），为nil则输出到标准输出
+	Logger Logger `json:"-" yaml:"-"`
+
+	// 对象被controller淘汰后的回调，在controller的协程中调用，不能阻塞。obj为删除前存储的对象（序列化存储模式下为解码后的对象）
+	OnEvict RemoveFunc `json:"-" yaml:"-"`
+	// 对象过期删除后的回调（包括读取时发现过期），调用的协程不确定，不能阻塞
+	OnExpire RemoveFunc `json:"-" yaml:"-"`
+}
+
+// AdmissionConfig 准入策略（TinyLFU）的配置。
+// 启用后，对象数量达到开始淘汰的比例（EvictionConfig.EliminateThreshold）时，新增对象的估算访问频率需要大于最近被淘汰对象的
+// 估算访问频率才能存入，避免只访问一次的对象（如扫描类的访问）挤掉热点对象。
+type AdmissionConfig struct {
+	// 是否对所有topic启用准入策略
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// 单独设置topic是否启用准入策略，覆盖Enabled。默认topic使用空字符串
+	Topics map[string]bool `json:"topics" yaml:"topics"`
+}
+
+// enabled 是否需要创建访问频率估算
+func (a AdmissionConfig) enabled() bool {
+	if a.Enabled {
+		return true
+	}
+	for _, v := range a.Topics {
+		if v {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize 填充默认值并检查参数
+func (o Options) normalize() (opts Options, err error) {
+	if o.ObjMaxCount > 1e8 || o.ObjMaxCount < 1e4 {
+		o.ObjMaxCount = int32(internal.DefaultObjCount)
+	}
+
+	if o.CapacityPolicy < CapacitySoft || o.CapacityPolicy > CapacityReject {
+		return o, fmt.Errorf("%w: 未知的CapacityPolicy(%d)", ErrInvalidConfig, o.CapacityPolicy)
+	}
+
+	if o.Shards == 0 {
+		o.Shards = storage.DefaultSegmentCount()
+	} else if !storage.ValidSegmentCount(o.Shards) {
+		return o, fmt.Errorf("%w: Shards(%d)需要为[1, %d]之间的2的幂", ErrInvalidConfig, o.Shards, storage.MaxSegmentCount)
+	}
+
+	if o.ControllerShards == 0 {
+		o.ControllerShards = 1
+		for o.ControllerShards < runtime.GOMAXPROCS(0) && o.ControllerShards < o.Shards {
+			o.ControllerShards <<= 1
+		}
+	} else if o.ControllerShards < 0 || o.ControllerShards > o.Shards || o.ControllerShards&(o.ControllerShards-1) != 0 {
+		return o, fmt.Errorf("%w: ControllerShards(%d)需要为[1, Shards(%d)]之间的2的幂", ErrInvalidConfig, o.ControllerShards, o.Shards)
+	}
+
+	if o.IntakeLimit < 0 {
+		return o, fmt.Errorf("%w: IntakeLimit(%d)不能小于0", ErrInvalidConfig, o.IntakeLimit)
+	}
+	if o.IntakePolicy < IntakeBlock || o.IntakePolicy > IntakeEvict {
+		return o, fmt.Errorf("%w: 未知的IntakePolicy(%d)", ErrInvalidConfig, o.IntakePolicy)
+	}
+
+	if o.LockFreeReads && o.Codec != nil {
+		return o, fmt.Errorf("%w: LockFreeReads不能与Codec同时使用", ErrInvalidConfig)
+	}
+
+	if o.LockFreeReads && o.SlidingExpiration {
+		return o, fmt.Errorf("%w: LockFreeReads不能与SlidingExpiration同时使用", ErrInvalidConfig)
+	}
+
+	if o.MaxBytes < 0 {
+		return o, fmt.Errorf("%w: MaxBytes(%d)不能小于0", ErrInvalidConfig, o.MaxBytes)
+	}
+	if o.MaxBytes > 0 && o.Codec == nil {
+		return o, fmt.Errorf("%w: MaxBytes需要设置Codec", ErrInvalidConfig)
+	}
+
+	if o.DefaultTTL < 0 {
+		return o, fmt.Errorf("%w: DefaultTTL(%d)不能小于0", ErrInvalidConfig, o.DefaultTTL)
+	}
+	if o.TTLJitter < 0 || o.TTLJitter > 100 {
+		return o, fmt.Errorf("%w: TTLJitter(%d)需要在[0, 100]之间", ErrInvalidConfig, o.TTLJitter)
+	}
+	if o.MaxTopics == 0 {
+		o.MaxTopics = defaultMaxTopics
+	} else if o.MaxTopics < 0 || o.MaxTopics > math.MaxInt32 {
+		return o, fmt.Errorf("%w: MaxTopics(%d)需要在[1, %d]之间", ErrInvalidConfig, o.MaxTopics, math.MaxInt32)
+	}
+	if len(o.TopicTTL) > o.MaxTopics {
+		return o, fmt.Errorf("%w: TopicTTL的个数(%d)超过MaxTopics(%d)", ErrInvalidConfig, len(o.TopicTTL), o.MaxTopics)
+	}
+	for topic, cfg := range o.TopicTTL {
+		if err = cfg.validate(); err != nil {
+			return o, fmt.Errorf("%w: TopicTTL[%q].%v", ErrInvalidConfig, topic, err)
+		}
+	}
+
+	if o.MaxValueSize == 0 {
+		o.MaxValueSize = defaultMaxValueSize
+	} else if o.MaxValueSize < 0 || o.MaxValueSize > maxValueSize {
+		return o, fmt.Errorf("%w: MaxValueSize(%d)需要在[1, %d]之间", ErrInvalidConfig, o.MaxValueSize, maxValueSize)
+	}
+
+	if o.OffHeap && o.Codec == nil {
+		return o, fmt.Errorf("%w: OffHeap需要设置Codec", ErrInvalidConfig)
+	}
+	if o.OffHeap && !storage.OffHeapSupported {
+		return o, fmt.Errorf("%w: 当前平台不支持OffHeap", ErrInvalidConfig)
+	}
+
+	o.Eviction = o.Eviction.WithDefaults()
+	if err = o.Eviction.Validate(); err != nil {
+		return o, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	return o, nil
+}
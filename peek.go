@@ -0,0 +1,161 @@
+package objectCache
+
+import (
+	"math"
+	"objectCache/internal"
+	"time"
+)
+
+// 以下为不影响淘汰判断的读取接口，用于监控、调试、管理等场景：不计入访问次数（包括准入策略的访问频率估算），
+// 也不推迟滑动过期的时间，已经过期（还没有被删除）的对象视为不存在，但不会删除。缓存没有初始化、已经关闭时ok为false
+
+// Queue 对象所在的淘汰队列，见Meta
+type Queue int
+
+const (
+	// QueueIntake 已经存入，还没有被controller放入淘汰队列（积压中）
+	QueueIntake Queue = iota
+	// QueueInitial 初始队列，休息期间没有被访问则淘汰
+	QueueInitial
+	// QueueRest 分等级的休息队列，等级见Meta.Level
+	QueueRest
+	// QueueDestroy 删除队列，休息期间访问频率没有回升则淘汰
+	QueueDestroy
+	// QueueDirect 不纳入淘汰管理（SetDirect()或者积压达到上限时按IntakeDirect存储）
+	QueueDirect
+)
+
+func (q Queue) String() string {
+	switch q {
+	case QueueIntake:
+		return "intake"
+	case QueueInitial:
+		return "initial"
+	case QueueRest:
+		return "rest"
+	case QueueDestroy:
+		return "destroy"
+	case QueueDirect:
+		return "direct"
+	}
+	return "unknown"
+}
+
+// Meta 对象的淘汰状态，用于了解对象为什么被淘汰（或者没有被淘汰）。
+// 访问记录由controller批量处理，CurrentCount可能稍有延迟；各字段分别读取，controller同时处理此对象时可能不一致
+type Meta struct {
+	// 过期时间，不过期则为零值
+	Expire time.Time
+	// 剩余的过期时间（单位是秒），不过期为NoExpire
+	TTL int
+
+	// 所在的淘汰队列
+	Queue Queue
+	// 所在restQueue的等级（从0开始，等级越高休息时间越长），不在restQueue中为-1
+	Level int
+	// 固定的对象不会被淘汰
+	Pinned bool
+	// 存储时设置的优先级
+	Priority Priority
+
+	// 在当前队列休息期间被访问的单位时间个数（EvictionConfig.NodeUnitRestTime），离开队列时计入TotalCount
+	CurrentCount uint32
+	// 累计被访问的单位时间个数、存活时长（单位为秒），超过最大休息时间后等比例缩放
+	TotalCount uint32
+	TotalTime  uint32
+	// 根据TotalCount、TotalTime计算的访问频率（与Stats.AverageQf比较，低于平均值并且稳定性低的对象优先淘汰）
+	Qf uint64
+	// 最后一次计入访问次数的时间
+	LastReadTime time.Time
+}
+
+// Peek 根据字符切片型键值获取对象，不影响淘汰判断。使用默认 _DefaultTopic_
+// ok 为false则说明cache里面已经不存在此对象
+func Peek(key []byte) (obj interface{}, ok bool) {
+	obj, _, ok = peek("", key, false)
+	return obj, ok
+}
+
+// PeekByTopic 根据字符切片型键值获取对象，不影响淘汰判断。topic为空则使用默认 _DefaultTopic_，返回值同Peek()
+func PeekByTopic(topic string, key []byte) (obj interface{}, ok bool) {
+	obj, _, ok = peek(topic, key, false)
+	return obj, ok
+}
+
+// GetWithMeta 与Peek()相同，同时返回对象的淘汰状态。使用默认 _DefaultTopic_
+func GetWithMeta(key []byte) (obj interface{}, meta Meta, ok bool) {
+	return peek("", key, true)
+}
+
+// GetWithMetaByTopic 与PeekByTopic()相同，同时返回对象的淘汰状态。topic为空则使用默认 _DefaultTopic_
+func GetWithMetaByTopic(topic string, key []byte) (obj interface{}, meta Meta, ok bool) {
+	return peek(topic, key, true)
+}
+
+// peek 获取对象，withMeta为true则同时返回淘汰状态。序列化存储模式下解码，数据不是[]byte或者解码失败视为对象不存在
+func peek(topic string, key []byte, withMeta bool) (obj interface{}, meta Meta, ok bool) {
+	if checkState() != nil {
+		return nil, meta, false
+	}
+
+	hash := internal.HashFunc(topicKey(topic, key))
+	obj, m, ok := c.segments.Of(hash).Peek(hash)
+	if !ok {
+		return nil, meta, false
+	}
+
+	now := internal.Now()
+	if m.Expire != math.MaxUint32 && now > m.Expire {
+		return nil, meta, false
+	}
+
+	if c.codec != nil {
+		data, isBytes := obj.([]byte)
+		if !isBytes {
+			return nil, meta, false
+		}
+		var err error
+		if obj, err = c.codec.Unmarshal(data); err != nil {
+			return nil, meta, false
+		}
+	}
+
+	if withMeta {
+		meta = c.meta(m, now)
+	}
+	return obj, meta, true
+}
+
+// meta 转换node的淘汰状态
+func (c *objectCache) meta(m internal.NodeMeta, now uint32) (meta Meta) {
+	meta = Meta{
+		TTL:          NoExpire,
+		Level:        -1,
+		Pinned:       m.Pinned,
+		Priority:     Priority(m.Priority),
+		CurrentCount: m.CurrentCount,
+		TotalCount:   m.TotalCount,
+		TotalTime:    m.TotalTime,
+		Qf:           c.controller.Qf(m.TotalCount, m.TotalTime),
+		LastReadTime: time.Unix(int64(m.LastReadTime), 0),
+	}
+	if m.Expire != math.MaxUint32 {
+		meta.Expire = time.Unix(int64(m.Expire), 0)
+		meta.TTL = int(m.Expire - now)
+	}
+
+	switch {
+	case m.Direct:
+		meta.Queue = QueueDirect
+	case m.Queue == internal.QueueNone:
+		meta.Queue = QueueIntake
+	case m.Queue == internal.QueueInitial:
+		meta.Queue = QueueInitial
+	case m.Queue == internal.QueueDestroy:
+		meta.Queue = QueueDestroy
+	default:
+		meta.Queue = QueueRest
+		meta.Level = int(m.Queue - internal.QueueRest)
+	}
+	return meta
+}
@@ -0,0 +1,71 @@
+package objectCache
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPeek_Total(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+
+	withNew(t, func() {
+		_ = SetWithPriorityByTopic("peek", []byte("p1"), "v1", 60, 2)
+		PinByTopic("peek", []byte("p1"))
+		SetDirect([]byte("p2"), "v2", 0)
+
+		// controller可能还没有放入淘汰队列
+		obj, meta, ok := GetWithMetaByTopic("peek", []byte("p1"))
+		if !ok || obj.(string) != "v1" || meta.Queue != QueueIntake && meta.Queue != QueueInitial || meta.Level != -1 || meta.TTL != 60 ||
+			meta.Expire.Unix() != clock.Now().Unix()+60 || !meta.Pinned || meta.Priority != 2 {
+			t.Error("失败1", meta)
+		}
+		if _, meta, _ = GetWithMeta([]byte("p2")); meta.Queue != QueueDirect || meta.TTL != NoExpire || !meta.Expire.IsZero() {
+			t.Error("失败2", meta)
+		}
+		if meta.Queue.String() != "direct" {
+			t.Error("失败3")
+		}
+
+		hash := KeyHash("peek", []byte("p1"))
+		_, _ = c.controller.EvictShard(context.Background(), hash, 0)
+		if _, meta, _ = GetWithMetaByTopic("peek", []byte("p1")); meta.Queue != QueueInitial || meta.Qf != 0 {
+			t.Error("失败4", meta)
+		}
+
+		// 不推迟滑动过期的时间，过期后视为不存在
+		clock.add(30 * time.Second)
+		if obj, ok = PeekByTopic("peek", []byte("p1")); !ok || obj.(string) != "v1" {
+			t.Error("失败5")
+		}
+		if ttl, _ := TTLByTopic("peek", []byte("p1")); ttl != 30 {
+			t.Error("失败6", ttl)
+		}
+		clock.add(31 * time.Second)
+		if _, ok = PeekByTopic("peek", []byte("p1")); ok {
+			t.Error("失败7")
+		}
+		if _, ok = Peek([]byte("p3")); ok {
+			t.Error("失败8")
+		}
+
+		_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+	},
+		WithSlidingExpiration(),
+		WithClock(clock),
+	)
+}
+
+func TestPeek_NotInitialized(t *testing.T) {
+	saved := c
+	c = nil
+	defer func() { c = saved }()
+
+	if _, ok := Peek([]byte("p1")); ok {
+		t.Error("失败1")
+	}
+	if _, _, ok := GetWithMetaByTopic("peek", []byte("p1")); ok {
+		t.Error("失败2")
+	}
+}
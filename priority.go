@@ -0,0 +1,152 @@
+package objectCache
+
+import (
+	"context"
+	"encoding/binary"
+	"objectCache/internal"
+)
+
+// Priority 对象的优先级。新增的对象先在initialQueue中休息，之后放入优先级对应等级的restQueue（超出则为最高等级），
+// 优先级越高则被检查的间隔越长，越不容易被淘汰；优先级不为PriorityNormal的对象在initialQueue中没有被访问也不会被淘汰。
+type Priority uint8
+
+const (
+	// PriorityNormal 默认优先级，从0级restQueue开始
+	PriorityNormal Priority = 0
+	// PriorityHighest 从最高等级的restQueue开始
+	PriorityHighest Priority = 255
+)
+
+// pin 设置对象是否固定，缓存没有初始化时返回false
+func pin(key []byte, pinned bool) (ok bool) {
+	if c == nil {
+		return false
+	}
+	hashVal := internal.HashFunc(key)
+	segID := c.segments.Index(hashVal)
+	return c.segments.List[segID].Pin(hashVal, pinned)
+}
+
+// SetWithPriority 缓存字符切片为键值的对象，并设置优先级。使用默认 _DefaultTopic_
+// key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期；priority只对新增的对象有效
+func SetWithPriority(key []byte, obj interface{}, expireSecond int, priority Priority) (err error) {
+	key = append(key, defaultTopic...)
+	return set(context.Background(), "", key, obj, expireSecond, priority)
+}
+
+// SetIntWithPriority 缓存一个以int型KEY的对象，并设置优先级。使用默认 _DefaultTopic_
+// key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期；priority只对新增的对象有效
+func SetIntWithPriority(key int64, obj interface{}, expireSecond int, priority Priority) (err error) {
+	var bKey [internal.DefaultKeySize]byte
+	binary.LittleEndian.PutUint64(bKey[:], uint64(key))
+	return SetWithPriority(bKey[:], obj, expireSecond, priority)
+}
+
+// SetWithPriorityByTopic 缓存字符切片为键值的对象，并设置优先级。topic为空则使用默认 _DefaultTopic_
+// key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期；priority只对新增的对象有效
+func SetWithPriorityByTopic(topic string, key []byte, obj interface{}, expireSecond int, priority Priority) (err error) {
+	if topic == "" {
+		key = append(key, defaultTopic...)
+	} else {
+		key = append(key, internal.String2Bytes(topic)...)
+	}
+
+	return set(context.Background(), topic, key, obj, expireSecond, priority)
+}
+
+// SetIntWithPriorityByTopic 缓存一个以int型KEY的对象，并设置优先级。topic为空则使用默认 _DefaultTopic_
+// key为键值；obj为存储对象；expireSecond为过期时间（单位是秒），如果为0则不过期；priority只对新增的对象有效
+func SetIntWithPriorityByTopic(topic string, key int64, obj interface{}, expireSecond int, priority Priority) (err error) {
+	var bKey [internal.DefaultKeySize]byte
+	binary.LittleEndian.PutUint64(bKey[:], uint64(key))
+	var hashKey []byte
+	if topic == "" {
+		hashKey = append(bKey[:], defaultTopic...)
+	} else {
+		hashKey = append(bKey[:], internal.String2Bytes(topic)...)
+	}
+
+	return set(context.Background(), topic, hashKey, obj, expireSecond, priority)
+}
+
+// Pin 固定字符切片为键值的对象，固定的对象不会被淘汰，但仍然会过期、计入对象数量。使用默认 _DefaultTopic_
+// ok返回为false则说明对象不存在
+func Pin(key []byte) (ok bool) {
+	key = append(key, defaultTopic...)
+	return pin(key, true)
+}
+
+// PinInt 固定int型键值的对象。使用默认 _DefaultTopic_
+// ok返回为false则说明对象不存在
+func PinInt(key int64) (ok bool) {
+	var bKey [internal.DefaultKeySize]byte
+	binary.LittleEndian.PutUint64(bKey[:], uint64(key))
+	return Pin(bKey[:])
+}
+
+// PinByTopic 固定字符切片为键值的对象。topic为空则使用默认 _DefaultTopic_
+// ok返回为false则说明对象不存在
+func PinByTopic(topic string, key []byte) (ok bool) {
+	if topic == "" {
+		key = append(key, defaultTopic...)
+	} else {
+		key = append(key, internal.String2Bytes(topic)...)
+	}
+
+	return pin(key, true)
+}
+
+// PinIntByTopic 固定int型键值的对象。topic为空则使用默认 _DefaultTopic_
+// ok返回为false则说明对象不存在
+func PinIntByTopic(topic string, key int64) (ok bool) {
+	var bKey [internal.DefaultKeySize]byte
+	binary.LittleEndian.PutUint64(bKey[:], uint64(key))
+	var hashKey []byte
+	if topic == "" {
+		hashKey = append(bKey[:], defaultTopic...)
+	} else {
+		hashKey = append(bKey[:], internal.String2Bytes(topic)...)
+	}
+	return pin(hashKey, true)
+}
+
+// Unpin 取消固定字符切片为键值的对象，之后按正常的淘汰算法处理。使用默认 _DefaultTopic_
+// ok返回为false则说明对象不存在
+func Unpin(key []byte) (ok bool) {
+	key = append(key, defaultTopic...)
+	return pin(key, false)
+}
+
+// UnpinInt 取消固定int型键值的对象。使用默认 _DefaultTopic_
+// ok返回为false则说明对象不存在
+func UnpinInt(key int64) (ok bool) {
+	var bKey [internal.DefaultKeySize]byte
+	binary.LittleEndian.PutUint64(bKey[:], uint64(key))
+	return Unpin(bKey[:])
+}
+
+// UnpinByTopic 取消固定字符切片为键值的对象。topic为空则使用默认 _DefaultTopic_
+// ok返回为false则说明对象不存在
+func UnpinByTopic(topic string, key []byte) (ok bool) {
+	if topic == "" {
+		key = append(key, defaultTopic...)
+	} else {
+		key = append(key, internal.String2Bytes(topic)...)
+	}
+
+	return pin(key, false)
+}
+
+// UnpinIntByTopic 取消固定int型键值的对象。topic为空则使用默认 _DefaultTopic_
+// ok返回为false则说明对象不存在
+func UnpinIntByTopic(topic string, key int64) (ok bool) {
+	var bKey [internal.DefaultKeySize]byte
+	binary.LittleEndian.PutUint64(bKey[:], uint64(key))
+	var hashKey []byte
+	if topic == "" {
+		hashKey = append(bKey[:], defaultTopic...)
+	} else {
+		hashKey = append(bKey[:], internal.String2Bytes(topic)...)
+	}
+	return pin(hashKey, false)
+}
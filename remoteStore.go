@@ -0,0 +1,66 @@
+package objectCache
+
+import (
+	"sync"
+	"time"
+)
+
+// RemoteStore 二级缓存（如Redis）的接口，Tiered使用。
+// Get的ok为false表示对象不存在，err只用于表示访问出错；ttl为0表示不过期
+type RemoteStore interface {
+	Get(key []byte) (value []byte, ok bool, err error)
+	Set(key []byte, value []byte, ttl time.Duration) error
+	Del(key []byte) error
+}
+
+// MemoryRemoteStore 保存在内存中的RemoteStore，用于测试
+type MemoryRemoteStore struct {
+	sync.RWMutex
+	items map[string]memoryItem
+}
+
+type memoryItem struct {
+	value []byte
+	// 过期时间，为零值则不过期
+	expire time.Time
+}
+
+func NewMemoryRemoteStore() (s *MemoryRemoteStore) {
+	return &MemoryRemoteStore{items: make(map[string]memoryItem)}
+}
+
+func (s *MemoryRemoteStore) Get(key []byte) (value []byte, ok bool, err error) {
+	s.RLock()
+	item, ok := s.items[string(key)]
+	s.RUnlock()
+	if !ok || (!item.expire.IsZero() && time.Now().After(item.expire)) {
+		return nil, false, nil
+	}
+	return append([]byte(nil), item.value...), true, nil
+}
+
+func (s *MemoryRemoteStore) Set(key []byte, value []byte, ttl time.Duration) (err error) {
+	item := memoryItem{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		item.expire = time.Now().Add(ttl)
+	}
+	s.Lock()
+	s.items[string(key)] = item
+	s.Unlock()
+	return nil
+}
+
+func (s *MemoryRemoteStore) Del(key []byte) (err error) {
+	s.Lock()
+	delete(s.items, string(key))
+	s.Unlock()
+	return nil
+}
+
+// Len 对象的个数（包括已经过期的对象）
+func (s *MemoryRemoteStore) Len() (count int) {
+	s.RLock()
+	count = len(s.items)
+	s.RUnlock()
+	return count
+}
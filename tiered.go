@@ -0,0 +1,204 @@
+package objectCache
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// TieredMode 二级缓存的写入模式，读取时一级缓存不存在的对象都会从二级缓存中加载（read-through）
+type TieredMode int
+
+const (
+	// TieredReadThrough 只写入一级缓存，二级缓存由其他系统写入，只用于加载一级缓存中不存在的对象
+	TieredReadThrough TieredMode = iota
+	// TieredWriteThrough 同步写入二级缓存，成功后再写入一级缓存
+	TieredWriteThrough
+	// TieredWriteBehind 写入一级缓存后立即返回，由后台按顺序写入二级缓存
+	TieredWriteBehind
+)
+
+// 默认的后台写入队列长度
+const defaultWriteBehindQueueSize = 1024
+
+// ErrTieredClosed Tiered已经关闭
+var ErrTieredClosed = errors.New("objectCache: Tiered已经关闭")
+
+// TieredOptions Tiered的配置
+type TieredOptions struct {
+	// 写入模式
+	Mode TieredMode
+	// 对象的编解码，为nil则使用GobCodec
+	Codec Codec
+	// 对象在一级缓存中使用的topic，为空则使用默认topic
+	Topic string
+	// 从二级缓存中加载的对象在一级缓存中的过期时间（单位是秒），为0则不过期
+	LoadExpireSecond int
+	// 后台写入的队列长度（TieredWriteBehind），为0则使用默认值1024，队列满时Set()、Del()阻塞
+	WriteBehindQueueSize int
+	// 后台写入二级缓存失败时调用（TieredWriteBehind），为nil则忽略错误
+	OnError func(key []byte, err error)
+}
+
+// Tiered 二级缓存，objectCache为一级缓存，RemoteStore（如Redis）为二级缓存
+type Tiered struct {
+	remote RemoteStore
+	opts   TieredOptions
+
+	// 后台写入（TieredWriteBehind）
+	sync.RWMutex
+	ops     chan tieredOp
+	pending sync.WaitGroup
+	closed  bool
+	done    chan struct{}
+}
+
+// tieredOp 后台写入的操作，value为nil表示删除
+type tieredOp struct {
+	key   []byte
+	value []byte
+	ttl   time.Duration
+}
+
+// NewTiered 创建二级缓存，需要先初始化objectCache
+func NewTiered(remote RemoteStore, opts TieredOptions) (t *Tiered, err error) {
+	if c == nil {
+		return nil, ErrNotInitialized
+	}
+	if remote == nil {
+		return nil, errors.New("objectCache: RemoteStore不能为nil")
+	}
+	if opts.Mode < TieredReadThrough || opts.Mode > TieredWriteBehind {
+		return nil, errors.New("objectCache: 未知的TieredMode")
+	}
+	if opts.Codec == nil {
+		opts.Codec = GobCodec{}
+	}
+
+	if opts.WriteBehindQueueSize <= 0 {
+		opts.WriteBehindQueueSize = defaultWriteBehindQueueSize
+	}
+
+	t = &Tiered{remote: remote, opts: opts}
+	if opts.Mode == TieredWriteBehind {
+		t.ops = make(chan tieredOp, opts.WriteBehindQueueSize)
+		t.done = make(chan struct{})
+		go t.writeBehind()
+	}
+	return t, nil
+}
+
+// Get 获取对象，一级缓存中不存在则从二级缓存中加载，并存入一级缓存。
+// ok为false并且err为nil说明两级缓存中都不存在此对象
+func (t *Tiered) Get(key []byte) (obj interface{}, ok bool, err error) {
+	if obj, ok = GetByTopic(t.opts.Topic, key); ok {
+		return obj, true, nil
+	}
+
+	data, ok, err := t.remote.Get(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if obj, err = t.opts.Codec.Unmarshal(data); err != nil {
+		return nil, false, err
+	}
+
+	// 一级缓存拒绝存入（准入策略、严格容量模式）不影响本次读取
+	_ = SetByTopic(t.opts.Topic, key, obj, t.opts.LoadExpireSecond)
+	return obj, true, nil
+}
+
+// Set 存储对象，expireSecond为两级缓存的过期时间（单位是秒），如果为0则不过期
+func (t *Tiered) Set(key []byte, obj interface{}, expireSecond int) (err error) {
+	if t.opts.Mode == TieredReadThrough {
+		return SetByTopic(t.opts.Topic, key, obj, expireSecond)
+	}
+
+	data, err := t.opts.Codec.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	ttl := time.Duration(expireSecond) * time.Second
+
+	if t.opts.Mode == TieredWriteThrough {
+		if err = t.remote.Set(key, data, ttl); err != nil {
+			return err
+		}
+		return SetByTopic(t.opts.Topic, key, obj, expireSecond)
+	}
+
+	if err = SetByTopic(t.opts.Topic, key, obj, expireSecond); err != nil {
+		return err
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return t.enqueue(tieredOp{key: append([]byte(nil), key...), value: data, ttl: ttl})
+}
+
+// Del 从两级缓存中删除对象
+func (t *Tiered) Del(key []byte) (err error) {
+	DelByTopic(t.opts.Topic, key)
+
+	if t.opts.Mode == TieredWriteBehind {
+		return t.enqueue(tieredOp{key: append([]byte(nil), key...)})
+	}
+	return t.remote.Del(key)
+}
+
+// Flush 等待后台写入队列中已有的操作完成
+func (t *Tiered) Flush() {
+	if t.ops != nil {
+		t.pending.Wait()
+	}
+}
+
+// Close 关闭Tiered，等待后台写入完成。关闭后Set()、Del()返回ErrTieredClosed（TieredWriteBehind）
+func (t *Tiered) Close() (err error) {
+	if t.ops == nil {
+		return nil
+	}
+
+	t.Lock()
+	if t.closed {
+		t.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.ops)
+	t.Unlock()
+
+	<-t.done
+	return nil
+}
+
+// enqueue 加入后台写入队列，队列满时阻塞
+func (t *Tiered) enqueue(op tieredOp) (err error) {
+	t.RLock()
+	defer t.RUnlock()
+	if t.closed {
+		return ErrTieredClosed
+	}
+
+	t.pending.Add(1)
+	t.ops <- op
+	return nil
+}
+
+// writeBehind 按顺序写入二级缓存
+func (t *Tiered) writeBehind() {
+	defer close(t.done)
+
+	for op := range t.ops {
+		var err error
+		if op.value == nil {
+			err = t.remote.Del(op.key)
+		} else {
+			err = t.remote.Set(op.key, op.value, op.ttl)
+		}
+		if err != nil && t.opts.OnError != nil {
+			t.opts.OnError(op.key, err)
+		}
+		t.pending.Done()
+	}
+}
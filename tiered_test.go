@@ -0,0 +1,114 @@
+package objectCache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// failRemoteStore 写入总是失败
+type failRemoteStore struct {
+	*MemoryRemoteStore
+}
+
+func (s failRemoteStore) Set(key []byte, value []byte, ttl time.Duration) error {
+	return errors.New("set failed")
+}
+
+func TestTiered_ReadThrough(t *testing.T) {
+	InitDefaultObjectCache()
+
+	remote := NewMemoryRemoteStore()
+	tiered, err := NewTiered(remote, TieredOptions{Topic: "tieredRT", Codec: BytesCodec{}})
+	if err != nil {
+		t.Fatal("失败1", err)
+	}
+
+	_ = remote.Set([]byte("k1"), []byte("v1"), 0)
+	obj, ok, err := tiered.Get([]byte("k1"))
+	if !ok || err != nil || string(obj.([]byte)) != "v1" {
+		t.Error("失败2")
+	}
+	// 已经加载到一级缓存
+	if _, ok = GetByTopic("tieredRT", []byte("k1")); !ok {
+		t.Error("失败3")
+	}
+
+	// 只写入一级缓存
+	_ = tiered.Set([]byte("k2"), []byte("v2"), 0)
+	if _, ok, _ = remote.Get([]byte("k2")); ok {
+		t.Error("失败4")
+	}
+
+	if tiered.Del([]byte("k1")) != nil || remote.Len() != 0 {
+		t.Error("失败5")
+	}
+	if _, ok, err = tiered.Get([]byte("k1")); ok || err != nil {
+		t.Error("失败6")
+	}
+}
+
+func TestTiered_WriteThrough(t *testing.T) {
+	InitDefaultObjectCache()
+
+	remote := NewMemoryRemoteStore()
+	tiered, _ := NewTiered(remote, TieredOptions{Mode: TieredWriteThrough, Topic: "tieredWT"})
+
+	if err := tiered.Set([]byte("k1"), 100, 0); err != nil {
+		t.Error("失败1", err)
+	}
+	data, ok, _ := remote.Get([]byte("k1"))
+	if !ok {
+		t.Fatal("失败2")
+	}
+	if obj, _ := (GobCodec{}).Unmarshal(data); obj != 100 {
+		t.Error("失败3")
+	}
+
+	// 二级缓存写入失败则不写入一级缓存
+	failed, _ := NewTiered(failRemoteStore{NewMemoryRemoteStore()}, TieredOptions{Mode: TieredWriteThrough, Topic: "tieredWT"})
+	if failed.Set([]byte("k2"), 200, 0) == nil {
+		t.Error("失败4")
+	}
+	if _, ok = GetByTopic("tieredWT", []byte("k2")); ok {
+		t.Error("失败5")
+	}
+}
+
+func TestTiered_WriteBehind(t *testing.T) {
+	InitDefaultObjectCache()
+
+	remote := NewMemoryRemoteStore()
+	tiered, _ := NewTiered(remote, TieredOptions{Mode: TieredWriteBehind, Topic: "tieredWB", Codec: BytesCodec{}})
+
+	for i := 0; i < 100; i++ {
+		if err := tiered.Set([]byte{byte(i)}, "v", 0); err != nil {
+			t.Error("失败1", err)
+		}
+	}
+	_ = tiered.Del([]byte{0})
+	tiered.Flush()
+	if remote.Len() != 99 {
+		t.Error("失败2", remote.Len())
+	}
+
+	var failedKeys int
+	failed, _ := NewTiered(failRemoteStore{NewMemoryRemoteStore()}, TieredOptions{
+		Mode:    TieredWriteBehind,
+		Topic:   "tieredWB",
+		Codec:   BytesCodec{},
+		OnError: func(key []byte, err error) { failedKeys++ },
+	})
+	_ = failed.Set([]byte("k"), "v", 0)
+	_ = failed.Close()
+	if failedKeys != 1 || failed.Set([]byte("k"), "v", 0) != ErrTieredClosed {
+		t.Error("失败3")
+	}
+
+	_ = tiered.Close()
+
+	// 清除剩余的对象，不影响其他测试
+	_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+}
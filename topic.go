@@ -0,0 +1,60 @@
+package objectCache
+
+import (
+	"objectCache/internal"
+)
+
+// topicID 获取topic的编号，第一次使用的topic分配新的编号。默认topic的编号为0。
+// 编号不回收（DropTopic()不释放），topic的个数达到Options.MaxTopics后新的topic返回false
+func (c *objectCache) topicID(topic string) (id uint32, ok bool) {
+	if topic == "" || topic == string(defaultTopic) {
+		return 0, true
+	}
+
+	if v, ok := c.topicIDs.Load(topic); ok {
+		return v.(uint32), true
+	}
+
+	c.topicLock.Lock()
+	defer c.topicLock.Unlock()
+	if v, ok := c.topicIDs.Load(topic); ok {
+		return v.(uint32), true
+	}
+	if c.topicSeq >= c.maxTopics {
+		return 0, false
+	}
+	c.topicSeq++
+	c.topicIDs.Store(topic, c.topicSeq)
+	return c.topicSeq, true
+}
+
+// dropTopic 删除topic下的所有对象，需要遍历所有对象
+func (c *objectCache) dropTopic(topic string) (count int) {
+	var id uint32
+	if topic != "" && topic != string(defaultTopic) {
+		v, ok := c.topicIDs.Load(topic)
+		if !ok {
+			return 0
+		}
+		id = v.(uint32)
+	}
+
+	match := func(n *internal.Node) bool {
+		return n.TopicID == id
+	}
+	for _, segment := range c.segments.List {
+		count += segment.DelFunc(match, c.recycle)
+	}
+	return count
+}
+
+// DropTopic 删除topic下的所有对象（包括不纳入淘汰管理的对象），并通知其他进程删除（启用了分布式失效时）。
+// topic为空则删除默认topic下的对象。需要遍历所有对象，不适合频繁调用。返回删除的个数，缓存没有初始化时返回0
+func DropTopic(topic string) (count int) {
+	if c == nil {
+		return 0
+	}
+	count = c.dropTopic(topic)
+	c.publishDropTopic(topic)
+	return count
+}
@@ -0,0 +1,87 @@
+package transport
+
+import (
+	"sync"
+)
+
+// Hub 进程内的消息中心，同一个Hub创建的LocalTransport之间互相传递消息，用于测试或者同一进程内的多个订阅者
+type Hub struct {
+	sync.RWMutex
+	transports map[*LocalTransport]struct{}
+}
+
+func NewHub() (h *Hub) {
+	return &Hub{transports: make(map[*LocalTransport]struct{})}
+}
+
+// NewTransport 创建一个连接到此Hub的LocalTransport
+func (h *Hub) NewTransport() (t *LocalTransport) {
+	t = &LocalTransport{hub: h}
+	h.Lock()
+	h.transports[t] = struct{}{}
+	h.Unlock()
+	return t
+}
+
+// publish 将消息同步发送给除from以外的所有LocalTransport
+func (h *Hub) publish(from *LocalTransport, msg []byte) {
+	h.RLock()
+	for t := range h.transports {
+		if t != from {
+			t.deliver(msg)
+		}
+	}
+	h.RUnlock()
+}
+
+// LocalTransport 进程内的Transport，Publish同步调用同一Hub中其他LocalTransport的handler
+type LocalTransport struct {
+	sync.RWMutex
+	hub     *Hub
+	handler func(msg []byte)
+	closed  bool
+}
+
+func (t *LocalTransport) Publish(msg []byte) (err error) {
+	t.RLock()
+	closed := t.closed
+	t.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+
+	t.hub.publish(t, msg)
+	return nil
+}
+
+func (t *LocalTransport) Subscribe(handler func(msg []byte)) (err error) {
+	t.Lock()
+	defer t.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
+	t.handler = handler
+	return nil
+}
+
+func (t *LocalTransport) Close() (err error) {
+	t.hub.Lock()
+	delete(t.hub.transports, t)
+	t.hub.Unlock()
+
+	t.Lock()
+	t.closed = true
+	t.handler = nil
+	t.Unlock()
+	return nil
+}
+
+// deliver 复制消息后调用handler，避免handler修改发布者的数据
+func (t *LocalTransport) deliver(msg []byte) {
+	t.RLock()
+	handler := t.handler
+	t.RUnlock()
+	if handler != nil {
+		handler(append([]byte(nil), msg...))
+	}
+}
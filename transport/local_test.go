@@ -0,0 +1,33 @@
+package transport
+
+import (
+	"testing"
+)
+
+func TestLocalTransport_Total(t *testing.T) {
+
+	hub := NewHub()
+	a := hub.NewTransport()
+	b := hub.NewTransport()
+
+	var gotA, gotB []string
+	_ = a.Subscribe(func(msg []byte) { gotA = append(gotA, string(msg)) })
+	_ = b.Subscribe(func(msg []byte) { gotB = append(gotB, string(msg)) })
+
+	// 不发送给自己
+	if err := a.Publish([]byte("x")); err != nil {
+		t.Error("失败1")
+	}
+	if len(gotA) != 0 || len(gotB) != 1 || gotB[0] != "x" {
+		t.Error("失败2")
+	}
+
+	_ = b.Close()
+	_ = a.Publish([]byte("y"))
+	if len(gotB) != 1 {
+		t.Error("失败3")
+	}
+	if b.Publish([]byte("z")) != ErrClosed {
+		t.Error("失败4")
+	}
+}
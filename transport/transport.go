@@ -0,0 +1,21 @@
+// Package transport 分布式失效使用的消息传输。
+// 多个进程各自持有objectCache时，一个进程删除对象后通过Transport通知其他进程删除同一个对象。
+package transport
+
+import (
+	"errors"
+)
+
+// ErrClosed Transport已经关闭
+var ErrClosed = errors.New("transport: 已经关闭")
+
+// Transport 发布/订阅消息的传输接口，消息的内容由objectCache编码，Transport只负责传递。
+// 实现需要保证可以并发调用Publish；Publish不需要把消息发送给自己（即使发送给自己，objectCache也会忽略）。
+type Transport interface {
+	// Publish 发布一条消息
+	Publish(msg []byte) error
+	// Subscribe 设置收到消息时的处理函数，handler在Transport内部的goroutine中调用，返回后msg不再有效
+	Subscribe(handler func(msg []byte)) error
+	// Close 关闭Transport，之后不再调用handler
+	Close() error
+}
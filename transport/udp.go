@@ -0,0 +1,154 @@
+package transport
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// 单条消息的最大长度
+const maxUDPMessageSize = 64 * 1024
+
+// 接收出错后等待的时间，连续出错时加倍，直到上限
+const (
+	minReceiveBackoff = 5 * time.Millisecond
+	maxReceiveBackoff = time.Second
+)
+
+// UDPTransport 基于UDP的Transport。
+// 监听地址为组播地址（如239.0.0.1:7946）时加入组播组，消息发布到组播组（同一组的所有进程都会收到，包括自己）；
+// 否则为单播，消息逐个发送给peers。UDP不保证送达，失效消息可能丢失，需要容忍短暂不一致的场景配合过期时间使用。
+type UDPTransport struct {
+	sync.RWMutex
+	conn    *net.UDPConn
+	sender  *net.UDPConn
+	peers   []*net.UDPAddr
+	handler func(msg []byte)
+	closed  bool
+}
+
+// NewUDPTransport 监听listen（如"127.0.0.1:7946"或者组播地址"239.0.0.1:7946"），peers为单播时接收消息的其他进程的地址
+func NewUDPTransport(listen string, peers ...string) (t *UDPTransport, err error) {
+	addr, err := net.ResolveUDPAddr("udp", listen)
+	if err != nil {
+		return nil, err
+	}
+
+	t = &UDPTransport{}
+	if addr.IP != nil && addr.IP.IsMulticast() {
+		if t.conn, err = net.ListenMulticastUDP("udp", nil, addr); err != nil {
+			return nil, err
+		}
+		t.peers = append(t.peers, addr)
+	} else if t.conn, err = net.ListenUDP("udp", addr); err != nil {
+		return nil, err
+	}
+
+	if t.sender, err = net.ListenUDP("udp", nil); err != nil {
+		t.conn.Close()
+		return nil, err
+	}
+
+	for _, peer := range peers {
+		if err = t.AddPeer(peer); err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+
+	go t.receive()
+	return t, nil
+}
+
+// Addr 实际监听的地址（监听端口为0时可以通过此方法获取端口）
+func (t *UDPTransport) Addr() net.Addr {
+	return t.conn.LocalAddr()
+}
+
+// AddPeer 增加一个接收消息的地址
+func (t *UDPTransport) AddPeer(peer string) (err error) {
+	addr, err := net.ResolveUDPAddr("udp", peer)
+	if err != nil {
+		return err
+	}
+	t.Lock()
+	t.peers = append(t.peers, addr)
+	t.Unlock()
+	return nil
+}
+
+func (t *UDPTransport) Publish(msg []byte) (err error) {
+	t.RLock()
+	defer t.RUnlock()
+	if t.closed {
+		return ErrClosed
+	}
+
+	// 发送给所有peer，返回第一个错误
+	for _, peer := range t.peers {
+		if _, e := t.sender.WriteToUDP(msg, peer); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (t *UDPTransport) Subscribe(handler func(msg []byte)) (err error) {
+	t.Lock()
+	defer t.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
+	t.handler = handler
+	return nil
+}
+
+func (t *UDPTransport) Close() (err error) {
+	t.Lock()
+	if t.closed {
+		t.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.handler = nil
+	t.Unlock()
+
+	err = t.conn.Close()
+	if e := t.sender.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// receive 接收消息直到关闭。接收出错（没有关闭）时等待一段时间后重试，避免持续出错时占满CPU
+func (t *UDPTransport) receive() {
+	buf := make([]byte, maxUDPMessageSize)
+	var backoff time.Duration
+	for {
+		n, _, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			t.RLock()
+			closed := t.closed
+			t.RUnlock()
+			if closed {
+				return
+			}
+
+			if backoff == 0 {
+				backoff = minReceiveBackoff
+			} else if backoff *= 2; backoff > maxReceiveBackoff {
+				backoff = maxReceiveBackoff
+			}
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+
+		t.RLock()
+		handler := t.handler
+		t.RUnlock()
+		if handler != nil {
+			handler(buf[:n])
+		}
+	}
+}
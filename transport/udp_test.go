@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUDPTransport_Unicast(t *testing.T) {
+
+	a, err := NewUDPTransport("127.0.0.1:0")
+	if err != nil {
+		t.Fatal("失败1", err)
+	}
+	defer a.Close()
+	b, err := NewUDPTransport("127.0.0.1:0", a.Addr().String())
+	if err != nil {
+		t.Fatal("失败2", err)
+	}
+	defer b.Close()
+
+	received := make(chan string, 1)
+	_ = a.Subscribe(func(msg []byte) { received <- string(msg) })
+
+	if err = b.Publish([]byte("hello")); err != nil {
+		t.Error("失败3", err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg != "hello" {
+			t.Error("失败4")
+		}
+	case <-time.After(time.Second * 2):
+		t.Error("失败5")
+	}
+
+	_ = b.Close()
+	if b.Publish([]byte("x")) != ErrClosed {
+		t.Error("失败6")
+	}
+}
+
+func TestUDPTransport_Multicast(t *testing.T) {
+
+	a, err := NewUDPTransport("239.255.77.1:17946")
+	if err != nil {
+		t.Skip("不支持组播", err)
+	}
+	defer a.Close()
+	b, err := NewUDPTransport("239.255.77.1:17946")
+	if err != nil {
+		t.Skip("不支持组播", err)
+	}
+	defer b.Close()
+
+	received := make(chan string, 2)
+	_ = a.Subscribe(func(msg []byte) { received <- string(msg) })
+
+	if err = b.Publish([]byte("hello")); err != nil {
+		t.Skip("不支持组播", err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg != "hello" {
+			t.Error("失败1")
+		}
+	case <-time.After(time.Second * 2):
+		t.Skip("没有收到组播消息，可能没有组播路由")
+	}
+}
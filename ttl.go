@@ -0,0 +1,83 @@
+package objectCache
+
+import (
+	"math"
+	"objectCache/internal"
+)
+
+// 以下为查看、修改对象过期时间的接口，不计入访问次数，也不推迟滑动过期（Options.SlidingExpiration）的时间。
+// 修改过期时间不会通知其他进程（分布式失效），也不使用随机抖动（Options.TTLJitter）。缓存没有初始化、已经关闭时ok为false
+
+// TTL 获取对象剩余的过期时间（单位是秒），不过期则返回NoExpire。使用默认 _DefaultTopic_
+// ok为false说明对象不存在或者已经过期
+func TTL(key []byte) (ttlSecond int, ok bool) {
+	return TTLByTopic("", key)
+}
+
+// TTLByTopic 获取对象剩余的过期时间，topic为空则使用默认 _DefaultTopic_，返回值同TTL()
+func TTLByTopic(topic string, key []byte) (ttlSecond int, ok bool) {
+	if checkState() != nil {
+		return 0, false
+	}
+	hash := internal.HashFunc(topicKey(topic, key))
+	expire, ok := c.segments.Of(hash).ExpireOf(hash)
+	if !ok {
+		return 0, false
+	}
+	if expire == math.MaxUint32 {
+		return NoExpire, true
+	}
+
+	now := internal.Now()
+	if now > expire {
+		return 0, false
+	}
+	return int(expire - now), true
+}
+
+// Touch 延长对象的过期时间，不需要重新存储对象。使用默认 _DefaultTopic_
+// expireSecond与Set()相同，为0则使用默认的过期时间；只在新的过期时间晚于原来的过期时间时修改，不过期的对象不受影响，
+// 不会取消过期时间（为NoExpire，或者为0并且没有默认的过期时间时不修改）。
+// ok为false说明对象不存在或者已经过期
+func Touch(key []byte, expireSecond int) (ok bool) {
+	return TouchByTopic("", key, expireSecond)
+}
+
+// TouchByTopic 延长对象的过期时间，topic为空则使用默认 _DefaultTopic_，参数、返回值同Touch()
+func TouchByTopic(topic string, key []byte, expireSecond int) (ok bool) {
+	if checkState() != nil {
+		return false
+	}
+	hash := internal.HashFunc(topicKey(topic, key))
+	return c.segments.Of(hash).Touch(hash, c.ttl(topic, expireSecond))
+}
+
+// Expire 修改对象的过期时间（可以提前），不需要重新存储对象。使用默认 _DefaultTopic_
+// expireSecond与Set()相同，为0则使用默认的过期时间，为NoExpire则不过期。ok为false说明对象不存在或者已经过期
+func Expire(key []byte, expireSecond int) (ok bool) {
+	return ExpireByTopic("", key, expireSecond)
+}
+
+// ExpireByTopic 修改对象的过期时间，topic为空则使用默认 _DefaultTopic_，参数、返回值同Expire()
+func ExpireByTopic(topic string, key []byte, expireSecond int) (ok bool) {
+	if checkState() != nil {
+		return false
+	}
+	hash := internal.HashFunc(topicKey(topic, key))
+	return c.segments.Of(hash).Expire(hash, c.ttl(topic, expireSecond))
+}
+
+// Persist 取消对象的过期时间，不需要重新存储对象。使用默认 _DefaultTopic_
+// ok为false说明对象不存在或者已经过期
+func Persist(key []byte) (ok bool) {
+	return PersistByTopic("", key)
+}
+
+// PersistByTopic 取消对象的过期时间，topic为空则使用默认 _DefaultTopic_，返回值同Persist()
+func PersistByTopic(topic string, key []byte) (ok bool) {
+	if checkState() != nil {
+		return false
+	}
+	hash := internal.HashFunc(topicKey(topic, key))
+	return c.segments.Of(hash).Expire(hash, NoExpire)
+}
@@ -0,0 +1,114 @@
+package objectCache
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTTL_Total(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+
+	withNew(t, func() {
+		_ = Set([]byte("t1"), 1, 100)
+		_ = SetByTopic("topic", []byte("t2"), 2, NoExpire)
+
+		if ttl, ok := TTL([]byte("t1")); !ok || ttl != 100 {
+			t.Error("失败1", ttl)
+		}
+		if ttl, ok := TTLByTopic("topic", []byte("t2")); !ok || ttl != NoExpire {
+			t.Error("失败2", ttl)
+		}
+		if _, ok := TTL([]byte("t2")); ok || Touch([]byte("t3"), 10) || Persist([]byte("t3")) {
+			t.Error("失败3")
+		}
+
+		// 延长、提前、使用默认的过期时间
+		if !Touch([]byte("t1"), 50) {
+			t.Error("失败4")
+		}
+		if ttl, _ := TTL([]byte("t1")); ttl != 100 {
+			t.Error("失败5", ttl)
+		}
+		Touch([]byte("t1"), 0)
+		if ttl, _ := TTL([]byte("t1")); ttl != 300 {
+			t.Error("失败6", ttl)
+		}
+		Expire([]byte("t1"), 10)
+		if ttl, _ := TTL([]byte("t1")); ttl != 10 {
+			t.Error("失败7", ttl)
+		}
+		if !ExpireByTopic("topic", []byte("t2"), 20) {
+			t.Error("失败8")
+		}
+
+		// 取消过期时间
+		Persist([]byte("t1"))
+		clock.add(30 * time.Second)
+		if ttl, ok := TTL([]byte("t1")); !ok || ttl != NoExpire {
+			t.Error("失败9", ttl)
+		}
+		if _, ok := TTLByTopic("topic", []byte("t2")); ok || PersistByTopic("topic", []byte("t2")) {
+			t.Error("失败10")
+		}
+
+		// 滑动过期：读取后推迟，TTL()不推迟
+		_ = Set([]byte("session"), "s", 60)
+		for i := 0; i < 5; i++ {
+			clock.add(40 * time.Second)
+			if _, ok := Get([]byte("session")); !ok {
+				t.Error("失败11", i)
+			}
+			if ttl, _ := TTL([]byte("session")); ttl != 60 {
+				t.Error("失败12", ttl)
+			}
+		}
+		clock.add(40 * time.Second)
+		TTL([]byte("session"))
+		clock.add(40 * time.Second)
+		if _, ok := Get([]byte("session")); ok {
+			t.Error("失败13")
+		}
+
+		_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+	},
+		WithDefaultTTL(300),
+		WithSlidingExpiration(),
+		WithClock(clock),
+	)
+}
+
+func TestTTL_Touch(t *testing.T) {
+	withNew(t, func() {
+		_ = Set([]byte("touch1"), 1, 100)
+
+		// 没有默认的过期时间时，0和NoExpire都不取消过期时间
+		if !Touch([]byte("touch1"), 0) || !Touch([]byte("touch1"), NoExpire) {
+			t.Error("失败1")
+		}
+		if ttl, ok := TTL([]byte("touch1")); !ok || ttl != 100 {
+			t.Error("失败2", ttl)
+		}
+		if !Touch([]byte("touch1"), 200) {
+			t.Error("失败3")
+		}
+		if ttl, _ := TTL([]byte("touch1")); ttl != 200 {
+			t.Error("失败4", ttl)
+		}
+
+		_, _ = c.controller.Evict(context.Background(), math.MaxInt32)
+	},
+		WithClock(&testClock{now: time.Now()}),
+	)
+}
+
+func TestTTL_NotInitialized(t *testing.T) {
+	saved := c
+	c = nil
+	defer func() { c = saved }()
+
+	if _, ok := TTL([]byte("t1")); ok || Touch([]byte("t1"), 10) || Expire([]byte("t1"), 10) || Persist([]byte("t1")) {
+		t.Error("失败1")
+	}
+}